@@ -0,0 +1,348 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/xiangxian/exchange/pkg"
+)
+
+// decimalFlag is a flag holding a pkg.Decimal.
+type decimalFlag struct {
+	d *pkg.Decimal
+}
+
+func (f decimalFlag) String() string {
+	if f.d == nil {
+		return ""
+	}
+	return f.d.String()
+}
+
+func (f decimalFlag) Set(s string) error {
+	d, err := pkg.NewDecimal(s)
+	if err != nil {
+		return err
+	}
+	*f.d = d
+	return nil
+}
+
+func runTime(ctx context.Context, c *cli, args []string) (*result, error) {
+	if err := parse(c.flags("time"), args); err != nil {
+		return nil, err
+	}
+	t, err := c.service.TimeCtx(ctx)
+	if err != nil {
+		return nil, err
+	}
+	ms := t.UnixNano() / int64(time.Millisecond)
+	return &result{
+		value:  map[string]interface{}{"time": ms},
+		header: []string{"time", "utc"},
+		rows:   [][]string{{strconv.FormatInt(ms, 10), t.UTC().Format(time.RFC3339Nano)}},
+	}, nil
+}
+
+func runDepth(ctx context.Context, c *cli, args []string) (*result, error) {
+	fs := c.flags("depth")
+	market := fs.String("market", "", "market, such as wonbtc")
+	limit := fs.Int("limit", 0, "levels per side")
+	if err := parse(fs, args, "market"); err != nil {
+		return nil, err
+	}
+	depth, err := c.service.DepthCtx(ctx, pkg.DepthRequest{Market: *market, Limit: *limit})
+	if err != nil {
+		return nil, err
+	}
+	var rows [][]string
+	for _, l := range depth.Asks {
+		rows = append(rows, []string{"ask", l.Price.String(), l.Amount.String()})
+	}
+	for _, l := range depth.Bids {
+		rows = append(rows, []string{"bid", l.Price.String(), l.Amount.String()})
+	}
+	return &result{value: depth, header: []string{"side", "price", "amount"}, rows: rows}, nil
+}
+
+func runTrades(ctx context.Context, c *cli, args []string) (*result, error) {
+	fs := c.flags("trades")
+	market := fs.String("market", "", "market, such as wonbtc")
+	limit := fs.Int("limit", 0, "number of trades")
+	if err := parse(fs, args, "market"); err != nil {
+		return nil, err
+	}
+	trades, err := c.service.RecentTradesCtx(ctx, pkg.TradeRequest{Market: *market, Limit: *limit})
+	if err != nil {
+		return nil, err
+	}
+	var rows [][]string
+	for _, t := range trades {
+		rows = append(rows, []string{strconv.FormatInt(t.Id, 10), t.Price.String(), t.Quantity.String(), strconv.FormatInt(t.CreateAt, 10)})
+	}
+	return &result{value: trades, header: []string{"id", "price", "qty", "time"}, rows: rows}, nil
+}
+
+func runTicker(ctx context.Context, c *cli, args []string) (*result, error) {
+	fs := c.flags("ticker")
+	market := fs.String("market", "", "market, such as wonbtc")
+	if err := parse(fs, args, "market"); err != nil {
+		return nil, err
+	}
+	ticker, err := c.service.TickerPriceCtx(ctx, pkg.TickerPriceRequest{Market: *market})
+	if err != nil {
+		return nil, err
+	}
+	return &result{value: ticker, header: []string{"market", "price"}, rows: [][]string{{ticker.Market, ticker.Price.String()}}}, nil
+}
+
+func runAccount(ctx context.Context, c *cli, args []string) (*result, error) {
+	fs := c.flags("account")
+	all := fs.Bool("all", false, "include currencies without balance")
+	if err := parse(fs, args); err != nil {
+		return nil, err
+	}
+	account, err := c.service.AccountCtx(ctx, pkg.AccountRequest{})
+	if err != nil {
+		return nil, err
+	}
+	var rows [][]string
+	for _, a := range account.Accounts {
+		if *all || !a.TotalBalance.IsZero() {
+			rows = append(rows, []string{a.Currency, a.TotalBalance.String(), a.Balance.String(), a.Locked.String()})
+		}
+	}
+	return &result{value: account, header: []string{"currency", "total", "available", "locked"}, rows: rows}, nil
+}
+
+func runMyTrades(ctx context.Context, c *cli, args []string) (*result, error) {
+	fs := c.flags("mytrades")
+	market := fs.String("market", "", "market, such as wonbtc")
+	limit := fs.Int("limit", 0, "number of trades")
+	from := fs.Int64("from", 0, "first trade id, latest trades if unset")
+	if err := parse(fs, args, "market"); err != nil {
+		return nil, err
+	}
+	trades, err := c.service.MyTradesCtx(ctx, pkg.TradeRequest{Market: *market, Limit: *limit, FromId: *from})
+	if err != nil {
+		return nil, err
+	}
+	var rows [][]string
+	for _, t := range trades {
+		rows = append(rows, []string{
+			strconv.FormatInt(t.Id, 10),
+			strconv.FormatInt(t.OrderId, 10),
+			string(t.Side),
+			t.Price.String(),
+			t.Quantity.String(),
+			strconv.FormatInt(t.CreateAt, 10),
+		})
+	}
+	return &result{value: trades, header: []string{"id", "order_id", "side", "price", "qty", "time"}, rows: rows}, nil
+}
+
+var orderCommands = map[string]func(ctx context.Context, c *cli, args []string) (*result, error){
+	"create":     runOrderCreate,
+	"get":        runOrderGet,
+	"list":       runOrderList,
+	"cancel":     runOrderCancel,
+	"cancel-all": runOrderCancelAll,
+}
+
+func runOrder(ctx context.Context, c *cli, args []string) (*result, error) {
+	if len(args) == 0 {
+		return nil, usageError("missing subcommand: create, get, list, cancel or cancel-all")
+	}
+	sub, ok := orderCommands[args[0]]
+	if !ok {
+		return nil, usageError(fmt.Sprintf("unknown subcommand %q: want create, get, list, cancel or cancel-all", args[0]))
+	}
+	return sub(ctx, c, args[1:])
+}
+
+func runOrderCreate(ctx context.Context, c *cli, args []string) (*result, error) {
+	fs := c.flags("order create")
+	var cor pkg.CreateOrderRequest
+	fs.StringVar(&cor.Market, "market", "", "market, such as wonbtc")
+	side := fs.String("side", "", "buy or sell")
+	ordType := fs.String("type", string(pkg.OrdTypeLimit), "order type")
+	tif := fs.String("tif", "", "time in force: gtc, ioc or fok")
+	fs.Var(decimalFlag{&cor.Price}, "price", "limit price")
+	fs.Var(decimalFlag{&cor.Volume}, "volume", "base volume")
+	fs.Var(decimalFlag{&cor.Funds}, "funds", "quote amount of a market order, instead of -volume")
+	fs.Var(decimalFlag{&cor.StopPrice}, "stop-price", "trigger price of stop orders")
+	fs.BoolVar(&cor.PostOnly, "post-only", false, "only add liquidity")
+	fs.StringVar(&cor.ClientOrderId, "client-id", "", "client order id, generated if unset")
+	recoverOrder := fs.Bool("recover", false, "look the order up instead of failing when the outcome is unknown")
+	if err := parse(fs, args, "market", "side"); err != nil {
+		return nil, err
+	}
+	var err error
+	if cor.Side, err = pkg.ParseSide(*side); err != nil {
+		return nil, usageError(err.Error())
+	}
+	if cor.OrdType, err = pkg.ParseOrdType(*ordType); err != nil {
+		return nil, usageError(err.Error())
+	}
+	if *tif != "" {
+		if cor.TimeInForce, err = pkg.ParseTimeInForce(*tif); err != nil {
+			return nil, usageError(err.Error())
+		}
+	}
+
+	var order *pkg.Order
+	if *recoverOrder {
+		order, err = c.service.CreateOrRecoverOrderCtx(ctx, cor)
+	} else {
+		order, err = c.service.CreateOrderCtx(ctx, cor)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return ordersResult(order, order), nil
+}
+
+func runOrderGet(ctx context.Context, c *cli, args []string) (*result, error) {
+	fs := c.flags("order get")
+	var or pkg.OrderRequest
+	fs.Int64Var(&or.Id, "id", 0, "order id")
+	fs.StringVar(&or.ClientOrderId, "client-id", "", "client order id, instead of -id")
+	if err := parse(fs, args); err != nil {
+		return nil, err
+	}
+	if or.Id == 0 && or.ClientOrderId == "" {
+		return nil, usageError("-id or -client-id is required")
+	}
+	order, err := c.service.GetOrderCtx(ctx, or)
+	if err != nil {
+		return nil, err
+	}
+	return ordersResult(order, order), nil
+}
+
+func runOrderList(ctx context.Context, c *cli, args []string) (*result, error) {
+	fs := c.flags("order list")
+	var osr pkg.OrdersRequest
+	fs.StringVar(&osr.Market, "market", "", "market, all markets if unset")
+	state := fs.String("state", "", "wait, done, cancel or reject")
+	side := fs.String("side", "", "buy or sell")
+	fs.Int64Var(&osr.StartAtStamp, "start", 0, "created at or after this stamp")
+	fs.Int64Var(&osr.EndAtStamp, "end", 0, "created at or before this stamp")
+	fs.IntVar(&osr.Limit, "limit", 0, "number of orders")
+	if err := parse(fs, args); err != nil {
+		return nil, err
+	}
+	var err error
+	if *state != "" {
+		if osr.State, err = pkg.ParseState(*state); err != nil {
+			return nil, usageError(err.Error())
+		}
+	}
+	if *side != "" {
+		if osr.Side, err = pkg.ParseSide(*side); err != nil {
+			return nil, usageError(err.Error())
+		}
+	}
+	orders, err := c.service.GetOrdersCtx(ctx, osr)
+	if err != nil {
+		return nil, err
+	}
+	return ordersResult(orders, orders...), nil
+}
+
+func runOrderCancel(ctx context.Context, c *cli, args []string) (*result, error) {
+	fs := c.flags("order cancel")
+	id := fs.Int64("id", 0, "order id")
+	if err := parse(fs, args, "id"); err != nil {
+		return nil, err
+	}
+	if err := c.service.CancelOrderCtx(ctx, pkg.CancelOrderRequest{Id: *id}); err != nil {
+		return nil, err
+	}
+	return &result{
+		value:  map[string]interface{}{"id": *id, "cancelled": true},
+		header: []string{"id", "cancelled"},
+		rows:   [][]string{{strconv.FormatInt(*id, 10), "true"}},
+	}, nil
+}
+
+func runOrderCancelAll(ctx context.Context, c *cli, args []string) (*result, error) {
+	fs := c.flags("order cancel-all")
+	market := fs.String("market", "", "market, all markets if unset")
+	side := fs.String("side", "", "buy or sell, both if unset")
+	if err := parse(fs, args); err != nil {
+		return nil, err
+	}
+	var s pkg.Side
+	if *side != "" {
+		var err error
+		if s, err = pkg.ParseSide(*side); err != nil {
+			return nil, usageError(err.Error())
+		}
+	}
+	report, err := c.service.CancelAllCtx(ctx, *market, s)
+	if report == nil {
+		return nil, err
+	}
+	value := cancelReport{Cancelled: report.Cancelled, Filled: report.Filled}
+	var rows [][]string
+	for _, o := range report.Cancelled {
+		rows = append(rows, []string{strconv.FormatInt(o.Id, 10), o.Market, "cancelled", ""})
+	}
+	for _, o := range report.Filled {
+		rows = append(rows, []string{strconv.FormatInt(o.Id, 10), o.Market, "filled", ""})
+	}
+	for _, f := range report.Failed {
+		rows = append(rows, []string{strconv.FormatInt(f.Order.Id, 10), f.Order.Market, "failed", f.Err.Error()})
+		value.Failed = append(value.Failed, cancelFailure{Order: f.Order, Err: f.Err.Error()})
+	}
+	if err == nil && len(report.Failed) > 0 {
+		err = errors.New(fmt.Sprintf("%d orders may still be open", len(report.Failed)))
+	}
+	if err != nil {
+		// Show what was done before reporting the failure.
+		writeResult(c.stdout, c.output, &result{value: value, header: []string{"id", "market", "outcome", "error"}, rows: rows})
+		return nil, err
+	}
+	return &result{value: value, header: []string{"id", "market", "outcome", "error"}, rows: rows}, nil
+}
+
+// cancelReport is a pkg.CancelReport as printed in JSON mode, with the
+// errors as text since most error values have nothing to encode.
+type cancelReport struct {
+	Cancelled []*pkg.Order
+	Filled    []*pkg.Order
+	Failed    []cancelFailure
+}
+
+type cancelFailure struct {
+	Order *pkg.Order
+	Err   string
+}
+
+// ordersResult prints orders as a table, and value as JSON.
+func ordersResult(value interface{}, orders ...*pkg.Order) *result {
+	var rows [][]string
+	for _, o := range orders {
+		rows = append(rows, []string{
+			strconv.FormatInt(o.Id, 10),
+			o.ClientOrderId,
+			o.Market,
+			string(o.Side),
+			string(o.OrdType),
+			string(o.State),
+			o.Price.String(),
+			o.Volume.String(),
+			o.RemainingVolume.String(),
+			strconv.FormatInt(o.CreatedAtStamp, 10),
+		})
+	}
+	return &result{
+		value:  value,
+		header: []string{"id", "client_order_id", "market", "side", "type", "state", "price", "volume", "remaining", "created_at"},
+		rows:   rows,
+	}
+}
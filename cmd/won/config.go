@@ -0,0 +1,83 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/xiangxian/exchange/pkg"
+)
+
+// config holds the exchange address and credentials. Values from the
+// environment override those of the config file.
+type config struct {
+	URL       string `json:"url"`
+	APIKey    string `json:"api_key"`
+	SecretKey string `json:"secret_key"`
+	// Signer selects the signing scheme. Its secret defaults to SecretKey.
+	Signer pkg.SignerConfig `json:"signer"`
+}
+
+// defaultConfigPath is read when neither -config nor WON_CONFIG is set. It
+// may be missing.
+func defaultConfigPath() string {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(home, ".won.json")
+}
+
+// loadConfig reads the config file at path, if any, then applies WON_URL,
+// WON_API_KEY, WON_SECRET_KEY, WON_SIGN_SCHEME, WON_PRIVATE_KEY_FILE and
+// WON_SIGNATURE_ENCODING from getenv. An explicit path must exist.
+func loadConfig(path string, getenv func(string) string) (*config, error) {
+	cfg := &config{}
+	explicit := path != ""
+	if !explicit {
+		path = getenv("WON_CONFIG")
+		explicit = path != ""
+	}
+	if !explicit {
+		path = defaultConfigPath()
+	}
+	if path != "" {
+		data, err := ioutil.ReadFile(path)
+		if err != nil && (explicit || !os.IsNotExist(err)) {
+			return nil, err
+		}
+		if err == nil {
+			if err := json.Unmarshal(data, cfg); err != nil {
+				return nil, errors.New(fmt.Sprintf("config %s unmarshal failed:%s", path, err.Error()))
+			}
+		}
+	}
+	if v := getenv("WON_URL"); v != "" {
+		cfg.URL = v
+	}
+	if v := getenv("WON_API_KEY"); v != "" {
+		cfg.APIKey = v
+	}
+	if v := getenv("WON_SECRET_KEY"); v != "" {
+		cfg.SecretKey, cfg.Signer.Secret = v, v
+	}
+	if v := getenv("WON_SIGN_SCHEME"); v != "" {
+		cfg.Signer.Scheme = v
+	}
+	if v := getenv("WON_PRIVATE_KEY_FILE"); v != "" {
+		cfg.Signer.PrivateKeyFile = v
+	}
+	if v := getenv("WON_SIGNATURE_ENCODING"); v != "" {
+		cfg.Signer.Encoding = pkg.SignatureEncoding(v)
+	}
+	if cfg.Signer.Secret == "" {
+		cfg.Signer.Secret = cfg.SecretKey
+	}
+	if cfg.URL == "" {
+		return nil, errors.New("no exchange url, set WON_URL or url in the config file")
+	}
+	return cfg, nil
+}
@@ -0,0 +1,179 @@
+// Command won calls the Won exchange API from the terminal.
+//
+//	won [-config file] [-o table|json|csv] <command> [flags]
+//
+// The exchange address and credentials are read from the config file, a
+// JSON object with url, api_key, secret_key and an optional signer, and from
+// the WON_URL, WON_API_KEY and WON_SECRET_KEY environment variables, which
+// take precedence. The signer object selects the signing scheme, as
+// pkg.SignerConfig, and may also be set with WON_SIGN_SCHEME,
+// WON_PRIVATE_KEY_FILE and WON_SIGNATURE_ENCODING. The config file defaults
+// to WON_CONFIG, then ~/.won.json.
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"sort"
+	"strings"
+
+	"github.com/xiangxian/exchange/pkg"
+)
+
+// cli is the state shared by the commands of one invocation.
+type cli struct {
+	service pkg.Service
+	stdout  io.Writer
+	stderr  io.Writer
+	output  string
+}
+
+type command struct {
+	usage string
+	run   func(ctx context.Context, c *cli, args []string) (*result, error)
+}
+
+var commands map[string]command
+
+func init() {
+	commands = map[string]command{
+		"time":     {"server time", runTime},
+		"depth":    {"order book of a market", runDepth},
+		"trades":   {"recent trades of a market", runTrades},
+		"ticker":   {"last price of a market", runTicker},
+		"account":  {"balances of the account", runAccount},
+		"order":    {"create|get|list|cancel|cancel-all orders", runOrder},
+		"mytrades": {"executions of the account in a market", runMyTrades},
+	}
+}
+
+func main() {
+	ctx, cancel := context.WithCancel(context.Background())
+	interrupt := make(chan os.Signal, 1)
+	signal.Notify(interrupt, os.Interrupt)
+	go func() {
+		<-interrupt
+		cancel()
+	}()
+	code := run(ctx, os.Args[1:], os.Stdout, os.Stderr, os.Getenv)
+	cancel()
+	os.Exit(code)
+}
+
+// run executes the command line args and returns the exit status.
+func run(ctx context.Context, args []string, stdout, stderr io.Writer, getenv func(string) string) int {
+	fs := flag.NewFlagSet("won", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	configPath := fs.String("config", "", "config file")
+	output := fs.String("o", outputTable, "output mode: table, json or csv")
+	fs.Usage = func() {
+		fmt.Fprintln(stderr, "usage: won [-config file] [-o table|json|csv] <command> [flags]")
+		fs.PrintDefaults()
+		fmt.Fprintln(stderr, "commands:")
+		var names []string
+		for name := range commands {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			fmt.Fprintf(stderr, "  %-9s %s\n", name, commands[name].usage)
+		}
+	}
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	switch *output {
+	case outputTable, outputJSON, outputCSV:
+	default:
+		// Checked before any call, so that an order is not placed only
+		// for its result to go unprinted.
+		fmt.Fprintf(stderr, "won: unknown output mode %q\n", *output)
+		fs.Usage()
+		return 2
+	}
+	if fs.NArg() == 0 {
+		fs.Usage()
+		return 2
+	}
+	cmd, ok := commands[fs.Arg(0)]
+	if !ok {
+		fmt.Fprintf(stderr, "won: unknown command %q\n", fs.Arg(0))
+		fs.Usage()
+		return 2
+	}
+
+	cfg, err := loadConfig(*configPath, getenv)
+	if err != nil {
+		fmt.Fprintf(stderr, "won: %s\n", err)
+		return 1
+	}
+	opts := []pkg.Option{pkg.WithContext(ctx), pkg.WithUserAgent("won-cli")}
+	if cfg.APIKey != "" {
+		opts = append(opts, pkg.WithAPIKey(cfg.APIKey))
+	}
+	if cfg.Signer.Secret != "" || cfg.Signer.PrivateKeyPEM != "" || cfg.Signer.PrivateKeyFile != "" {
+		signer, err := pkg.NewSigner(cfg.Signer)
+		if err != nil {
+			fmt.Fprintf(stderr, "won: %s\n", err)
+			return 1
+		}
+		opts = append(opts, pkg.WithSigner(signer))
+	}
+	c := &cli{service: pkg.NewService(cfg.URL, opts...), stdout: stdout, stderr: stderr, output: *output}
+
+	r, err := cmd.run(ctx, c, fs.Args()[1:])
+	if err == flag.ErrHelp {
+		return 2
+	}
+	if err != nil {
+		fmt.Fprintf(stderr, "won %s: %s\n", fs.Arg(0), err)
+		if _, ok := err.(usageError); ok {
+			return 2
+		}
+		return 1
+	}
+	if err := writeResult(stdout, c.output, r); err != nil {
+		fmt.Fprintf(stderr, "won: %s\n", err)
+		return 1
+	}
+	return 0
+}
+
+// usageError is a wrong command line, as opposed to a failed call.
+type usageError string
+
+func (e usageError) Error() string {
+	return string(e)
+}
+
+// flags returns a flag set for the command name whose errors go to stderr.
+func (c *cli) flags(name string) *flag.FlagSet {
+	fs := flag.NewFlagSet("won "+name, flag.ContinueOnError)
+	fs.SetOutput(c.stderr)
+	return fs
+}
+
+// parse parses args into fs and checks that every flag of required is set.
+func parse(fs *flag.FlagSet, args []string, required ...string) error {
+	if err := fs.Parse(args); err != nil {
+		if err == flag.ErrHelp {
+			return err
+		}
+		return usageError(err.Error())
+	}
+	if fs.NArg() > 0 {
+		return usageError(fmt.Sprintf("unexpected arguments: %s", strings.Join(fs.Args(), " ")))
+	}
+	set := make(map[string]bool)
+	fs.Visit(func(f *flag.Flag) { set[f.Name] = true })
+	for _, name := range required {
+		if !set[name] {
+			return usageError(fmt.Sprintf("-%s is required", name))
+		}
+	}
+	return nil
+}
@@ -0,0 +1,116 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/bmizerany/assert"
+)
+
+func newCLIServer(created *url.Values) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var data interface{}
+		switch r.URL.Path {
+		case "/api/v1/ticker/price":
+			data = map[string]string{"market": r.URL.Query().Get("market"), "price": "0.5"}
+		case "/api/v1/order/create":
+			r.ParseForm()
+			*created = r.Form
+			data = map[string]interface{}{"id": 7, "market": "wonbtc", "side": "buy", "ord_type": "limit", "state": "wait", "price": "0.5", "volume": "2"}
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{"data": data})
+	}))
+}
+
+func env(vars map[string]string) func(string) string {
+	return func(k string) string { return vars[k] }
+}
+
+func TestCLIOutputModes(t *testing.T) {
+	var created url.Values
+	server := newCLIServer(&created)
+	defer server.Close()
+	getenv := env(map[string]string{"WON_URL": server.URL, "WON_CONFIG": "/nonexistent"})
+
+	var stdout, stderr bytes.Buffer
+	code := run(context.Background(), []string{"ticker", "-market", "wonbtc"}, &stdout, &stderr, getenv)
+	// An explicit config file must exist.
+	assert.Equal(t, 1, code)
+	// The output mode is checked first.
+	code = run(context.Background(), []string{"-o", "yaml", "ticker", "-market", "wonbtc"}, &stdout, &stderr, getenv)
+	assert.Equal(t, 2, code)
+
+	getenv = env(map[string]string{"WON_URL": server.URL})
+	stdout.Reset()
+	code = run(context.Background(), []string{"-o", "csv", "ticker", "-market", "wonbtc"}, &stdout, &stderr, getenv)
+	assert.Equal(t, 0, code)
+	assert.Equal(t, "market,price\nwonbtc,0.5\n", stdout.String())
+
+	stdout.Reset()
+	code = run(context.Background(), []string{"ticker", "-market", "wonbtc"}, &stdout, &stderr, getenv)
+	assert.Equal(t, 0, code)
+	assert.Equal(t, true, strings.HasPrefix(stdout.String(), "MARKET  PRICE\nwonbtc  0.5"))
+
+	stdout.Reset()
+	code = run(context.Background(), []string{"-o", "json", "ticker", "-market", "wonbtc"}, &stdout, &stderr, getenv)
+	assert.Equal(t, 0, code)
+	var ticker map[string]string
+	assert.Equal(t, nil, json.Unmarshal(stdout.Bytes(), &ticker))
+	assert.Equal(t, "0.5", ticker["Price"])
+}
+
+func TestCLIOrderCreate(t *testing.T) {
+	var created url.Values
+	server := newCLIServer(&created)
+	defer server.Close()
+	getenv := env(map[string]string{"WON_URL": server.URL, "WON_API_KEY": "key", "WON_SECRET_KEY": "secret"})
+
+	var stdout, stderr bytes.Buffer
+	code := run(context.Background(), []string{"order", "create", "-market", "wonbtc", "-side", "sideways"}, &stdout, &stderr, getenv)
+	assert.Equal(t, 2, code)
+	assert.Equal(t, (url.Values)(nil), created)
+
+	code = run(context.Background(), []string{"-o", "yaml", "order", "create", "-market", "wonbtc", "-side", "buy", "-price", "0.5", "-volume", "2"}, &stdout, &stderr, getenv)
+	assert.Equal(t, 2, code)
+	assert.Equal(t, (url.Values)(nil), created)
+
+	code = run(context.Background(), []string{"-o", "csv", "order", "create", "-market", "wonbtc", "-side", "buy", "-price", "0.5", "-volume", "2"}, &stdout, &stderr, getenv)
+	assert.Equal(t, 0, code)
+	assert.Equal(t, "limit", created.Get("ord_type"))
+	assert.Equal(t, "0.5", created.Get("price"))
+	assert.NotEqual(t, "", created.Get("signature"))
+	assert.Equal(t, true, strings.Contains(stdout.String(), "\n7,"))
+}
+
+func TestCLICancelAllJSONErrors(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/api/v1/orders":
+			w.Write([]byte(`{"data":[{"id":5,"market":"wonbtc","state":"wait"}]}`))
+		case "/api/v1/order/cancel":
+			w.Write([]byte(`{"data":"pending"}`))
+		case "/api/v1/order":
+			w.Write([]byte(`{"data":{"id":5,"market":"wonbtc","state":"wait"}}`))
+		}
+	}))
+	defer server.Close()
+	getenv := env(map[string]string{"WON_URL": server.URL, "WON_API_KEY": "key", "WON_SECRET_KEY": "secret"})
+
+	var stdout, stderr bytes.Buffer
+	code := run(context.Background(), []string{"-o", "json", "order", "cancel-all"}, &stdout, &stderr, getenv)
+	assert.Equal(t, 1, code)
+	var report struct {
+		Failed []struct {
+			Err string
+		}
+	}
+	assert.Equal(t, nil, json.Unmarshal(stdout.Bytes(), &report))
+	assert.Equal(t, 1, len(report.Failed))
+	assert.Equal(t, "CancelOrder unexpected result:pending", report.Failed[0].Err)
+}
@@ -0,0 +1,48 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"text/tabwriter"
+)
+
+// Output modes selected with -o.
+const (
+	outputTable = "table"
+	outputJSON  = "json"
+	outputCSV   = "csv"
+)
+
+// result is what a command prints: value in JSON mode, the rows under header
+// in the table and CSV modes.
+type result struct {
+	value  interface{}
+	header []string
+	rows   [][]string
+}
+
+func writeResult(w io.Writer, mode string, r *result) error {
+	switch mode {
+	case outputJSON:
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(r.value)
+	case outputCSV:
+		cw := csv.NewWriter(w)
+		cw.Write(r.header)
+		cw.WriteAll(r.rows)
+		return cw.Error()
+	case outputTable:
+		tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+		fmt.Fprintln(tw, strings.ToUpper(strings.Join(r.header, "\t")))
+		for _, row := range r.rows {
+			fmt.Fprintln(tw, strings.Join(row, "\t"))
+		}
+		return tw.Flush()
+	}
+	return errors.New(fmt.Sprintf("unknown output mode:%q, want table, json or csv", mode))
+}
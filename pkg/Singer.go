@@ -1,21 +1,177 @@
 package pkg
 
 import (
+	"crypto"
+	"crypto/ed25519"
 	"crypto/hmac"
+	"crypto/rsa"
 	"crypto/sha256"
+	"crypto/sha512"
+	"crypto/x509"
+	"encoding/base64"
 	"encoding/hex"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"hash"
+	"io/ioutil"
 )
 
 type Signer interface {
 	Sign(payload []byte) string
 }
 
+// SignatureEncoding is how a signer writes the signature bytes.
+type SignatureEncoding string
+
+const (
+	// EncodingHex is lower case hex, the default.
+	EncodingHex SignatureEncoding = "hex"
+	// EncodingBase64 is standard, padded base64.
+	EncodingBase64 SignatureEncoding = "base64"
+)
+
+func (e SignatureEncoding) encode(sig []byte) string {
+	if e == EncodingBase64 {
+		return base64.StdEncoding.EncodeToString(sig)
+	}
+	return hex.EncodeToString(sig)
+}
+
+// HmacSigner signs with HMAC-SHA256, or HMAC over Hash if set.
 type HmacSigner struct {
-	Key []byte
+	Key      []byte
+	Hash     func() hash.Hash
+	Encoding SignatureEncoding
 }
 
 func (h *HmacSigner) Sign(payload []byte) string {
-	m := hmac.New(sha256.New, h.Key)
+	newHash := h.Hash
+	if newHash == nil {
+		newHash = sha256.New
+	}
+	m := hmac.New(newHash, h.Key)
 	m.Write(payload)
-	return hex.EncodeToString(m.Sum(nil))
+	return h.Encoding.encode(m.Sum(nil))
+}
+
+// Ed25519Signer signs with an Ed25519 private key.
+type Ed25519Signer struct {
+	Key      ed25519.PrivateKey
+	Encoding SignatureEncoding
+}
+
+func (s *Ed25519Signer) Sign(payload []byte) string {
+	return s.Encoding.encode(ed25519.Sign(s.Key, payload))
+}
+
+// RSASigner signs the SHA-256 digest of the payload with an RSA private key,
+// using PKCS #1 v1.5.
+type RSASigner struct {
+	Key      *rsa.PrivateKey
+	Encoding SignatureEncoding
+}
+
+// Sign returns an empty signature, which the exchange rejects, if the key is
+// too small for a SHA-256 digest. NewSigner refuses such keys.
+func (s *RSASigner) Sign(payload []byte) string {
+	digest := sha256.Sum256(payload)
+	sig, err := rsa.SignPKCS1v15(nil, s.Key, crypto.SHA256, digest[:])
+	if err != nil {
+		return ""
+	}
+	return s.Encoding.encode(sig)
+}
+
+// Signing schemes of SignerConfig.
+const (
+	SchemeHmacSHA256 = "hmac-sha256"
+	SchemeHmacSHA512 = "hmac-sha512"
+	SchemeEd25519    = "ed25519"
+	SchemeRSASHA256  = "rsa-sha256"
+)
+
+// SignerConfig selects a signer. The HMAC schemes use Secret, the key pair
+// schemes a PEM encoded private key, given inline or as a file. The scheme
+// defaults to hmac-sha256 and the encoding to hex.
+type SignerConfig struct {
+	Scheme         string            `json:"scheme"`
+	Secret         string            `json:"secret"`
+	PrivateKeyPEM  string            `json:"private_key_pem"`
+	PrivateKeyFile string            `json:"private_key_file"`
+	Encoding       SignatureEncoding `json:"encoding"`
+}
+
+// NewSigner returns the signer described by cfg.
+func NewSigner(cfg SignerConfig) (Signer, error) {
+	switch cfg.Encoding {
+	case "", EncodingHex, EncodingBase64:
+	default:
+		return nil, errors.New(fmt.Sprintf("unknown signature encoding:%q", cfg.Encoding))
+	}
+	switch cfg.Scheme {
+	case "", SchemeHmacSHA256:
+		return &HmacSigner{Key: []byte(cfg.Secret), Encoding: cfg.Encoding}, nil
+	case SchemeHmacSHA512:
+		return &HmacSigner{Key: []byte(cfg.Secret), Hash: sha512.New, Encoding: cfg.Encoding}, nil
+	case SchemeEd25519, SchemeRSASHA256:
+	default:
+		return nil, errors.New(fmt.Sprintf("unknown signing scheme:%q", cfg.Scheme))
+	}
+
+	data := []byte(cfg.PrivateKeyPEM)
+	if len(data) == 0 {
+		if cfg.PrivateKeyFile == "" {
+			return nil, errors.New(fmt.Sprintf("signing scheme %s needs a private key", cfg.Scheme))
+		}
+		var err error
+		if data, err = ioutil.ReadFile(cfg.PrivateKeyFile); err != nil {
+			return nil, err
+		}
+	}
+	key, err := ParsePrivateKeyPEM(data)
+	if err != nil {
+		return nil, err
+	}
+	switch k := key.(type) {
+	case ed25519.PrivateKey:
+		if cfg.Scheme == SchemeEd25519 {
+			return &Ed25519Signer{Key: k, Encoding: cfg.Encoding}, nil
+		}
+	case *rsa.PrivateKey:
+		if cfg.Scheme == SchemeRSASHA256 {
+			// PKCS #1 v1.5 needs room for the DigestInfo prefix and padding.
+			if k.Size() < 62 {
+				return nil, errors.New(fmt.Sprintf("rsa key of %d bits is too small", k.N.BitLen()))
+			}
+			return &RSASigner{Key: k, Encoding: cfg.Encoding}, nil
+		}
+	}
+	return nil, errors.New(fmt.Sprintf("private key of type %T does not match signing scheme %s", key, cfg.Scheme))
+}
+
+// ParsePrivateKeyPEM parses the first private key in data: PKCS #8, or
+// PKCS #1 for RSA. It returns an ed25519.PrivateKey or an *rsa.PrivateKey.
+func ParsePrivateKeyPEM(data []byte) (crypto.PrivateKey, error) {
+	for {
+		var block *pem.Block
+		block, data = pem.Decode(data)
+		if block == nil {
+			return nil, errors.New("no private key found in PEM data")
+		}
+		switch block.Type {
+		case "PRIVATE KEY":
+			key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+			if err != nil {
+				return nil, err
+			}
+			switch key.(type) {
+			case ed25519.PrivateKey, *rsa.PrivateKey:
+				return key, nil
+			}
+			return nil, errors.New(fmt.Sprintf("unsupported private key type %T", key))
+		case "RSA PRIVATE KEY":
+			return x509.ParsePKCS1PrivateKey(block.Bytes)
+		}
+	}
 }
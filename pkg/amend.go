@@ -0,0 +1,138 @@
+package pkg
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strconv"
+	"sync/atomic"
+	"time"
+)
+
+var (
+	// ErrOrderFilled is returned by AmendOrder when the order filled before
+	// it could be cancelled. No new order is placed.
+	ErrOrderFilled = errors.New("order filled before it could be replaced")
+	// ErrCancelNotConfirmed is returned by AmendOrder when the order was
+	// still open after the cancel. No new order is placed.
+	ErrCancelNotConfirmed = errors.New("order cancel not confirmed")
+)
+
+// AmendOrderRequest replaces the open order Id with Order.
+type AmendOrderRequest struct {
+	Id    int64
+	Order CreateOrderRequest
+}
+
+// AmendResult holds the replaced order, in its final state, and the order
+// replacing it.
+type AmendResult struct {
+	Old *Order
+	New *Order
+}
+
+// WithAmendEndpoint makes AmendOrder use the atomic replace endpoint of the
+// exchange. If the exchange answers 404 it falls back to cancel and create
+// for the lifetime of the service.
+func WithAmendEndpoint() Option {
+	return func(ws *wonService) {
+		ws.amend = 1
+	}
+}
+
+func (ws *wonService) AmendOrder(aor AmendOrderRequest) (*AmendResult, error) {
+	return ws.AmendOrderCtx(ws.Ctx, aor)
+}
+
+// AmendOrderCtx replaces an open order. With WithAmendEndpoint the exchange
+// swaps the orders atomically. Otherwise the old order is cancelled, its
+// terminal state confirmed with GetOrder, and only then is the new order
+// created; the new order is created as given, whatever part of the old one
+// executed. When the old order cannot be confirmed cancelled the result
+// holds it and no new order.
+func (ws *wonService) AmendOrderCtx(ctx context.Context, aor AmendOrderRequest) (*AmendResult, error) {
+	cor, err := ws.prepareOrder(ctx, aor.Order)
+	if err != nil {
+		return nil, err
+	}
+	if atomic.LoadInt32(&ws.amend) == 1 {
+		result, err := ws.replaceOrder(ctx, aor.Id, cor)
+		if !ws.amendUnsupported(err) {
+			return result, err
+		}
+	}
+
+	cancelErr := ws.CancelOrderCtx(ctx, CancelOrderRequest{Id: aor.Id})
+	old, err := ws.confirmCancel(ctx, aor.Id)
+	if err != nil {
+		if cancelErr != nil {
+			return nil, cancelErr
+		}
+		return nil, err
+	}
+	result := &AmendResult{Old: old}
+	switch old.State {
+	case StateDone:
+		return result, ErrOrderFilled
+	case StateWait:
+		if cancelErr != nil {
+			return result, cancelErr
+		}
+		return result, ErrCancelNotConfirmed
+	}
+	if result.New, err = ws.sendOrder(ctx, cor); err != nil {
+		return result, err
+	}
+	return result, nil
+}
+
+// amendUnsupported reports whether err means the replace endpoint does not
+// exist, and stops using it if so. A 404 for an unknown order does not.
+func (ws *wonService) amendUnsupported(err error) bool {
+	if e, ok := err.(*WonError); ok && e.StatusCode == http.StatusNotFound && e.Cause() == nil {
+		atomic.StoreInt32(&ws.amend, 0)
+		return true
+	}
+	return false
+}
+
+func (ws *wonService) replaceOrder(ctx context.Context, id int64, cor CreateOrderRequest) (*AmendResult, error) {
+	params := orderParams(cor)
+	params["id"] = strconv.FormatInt(id, 10)
+	ws.stamp(params, cor.Timestamp, cor.RecvWindow)
+
+	var raw struct {
+		Old *Order `json:"old"`
+		New *Order `json:"new"`
+	}
+	if err := ws.call(ctx, "POST", "api/v1/order/replace", params, true, true, &raw); err != nil {
+		return nil, err
+	}
+	if raw.New != nil && raw.New.ClientOrderId == "" {
+		raw.New.ClientOrderId = cor.ClientOrderId
+	}
+	return &AmendResult{Old: raw.Old, New: raw.New}, nil
+}
+
+// confirmCancel fetches the order until it reaches a terminal state, for at
+// most the attempts of the retry policy. It returns the last state seen.
+func (ws *wonService) confirmCancel(ctx context.Context, id int64) (*Order, error) {
+	attempts := ws.Retry.MaxAttempts
+	if attempts < 1 {
+		attempts = 1
+	}
+	for attempt := 0; ; attempt++ {
+		order, err := ws.GetOrderCtx(ctx, OrderRequest{Id: id})
+		if err != nil {
+			return nil, err
+		}
+		if order.State.IsTerminal() || attempt+1 >= attempts {
+			return order, nil
+		}
+		select {
+		case <-ctx.Done():
+			return order, nil
+		case <-time.After(ws.Retry.backoff(attempt, 0)):
+		}
+	}
+}
@@ -0,0 +1,257 @@
+package pkg
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+// DefaultBatchConcurrency is how many single order calls CreateOrders and
+// CancelOrders run at once when they cannot use the batch endpoints.
+const DefaultBatchConcurrency = 4
+
+// OrderResult is the outcome of one order of CreateOrders.
+type OrderResult struct {
+	Order *Order
+	Err   error
+}
+
+// CancelResult is the outcome of one cancellation of CancelOrders.
+type CancelResult struct {
+	Id  int64
+	Err error
+}
+
+// WithBatchEndpoints makes CreateOrders and CancelOrders use the batch
+// endpoints of the exchange, sending up to size items per call. If the
+// exchange answers 404 they fall back to single calls for the lifetime of
+// the service.
+func WithBatchEndpoints(size int) Option {
+	return func(ws *wonService) {
+		ws.batch.size = size
+	}
+}
+
+// WithBatchConcurrency sets how many single calls CreateOrders and
+// CancelOrders run at once. Calls still wait for the rate limiter.
+func WithBatchConcurrency(n int) Option {
+	return func(ws *wonService) {
+		ws.batch.concurrency = n
+	}
+}
+
+type batchConfig struct {
+	size        int
+	concurrency int
+	// unsupported is set once a batch endpoint answered 404.
+	unsupported int32
+}
+
+func (b *batchConfig) enabled() bool {
+	return b.size > 0 && atomic.LoadInt32(&b.unsupported) == 0
+}
+
+// fallback reports whether err means the batch endpoints do not exist, and
+// stops using them if so.
+func (b *batchConfig) fallback(err error) bool {
+	if e, ok := err.(*WonError); ok && e.StatusCode == http.StatusNotFound {
+		atomic.StoreInt32(&b.unsupported, 1)
+		return true
+	}
+	return false
+}
+
+func (ws *wonService) CreateOrders(orders []CreateOrderRequest) []OrderResult {
+	return ws.CreateOrdersCtx(ws.Ctx, orders)
+}
+func (ws *wonService) CancelOrders(ids []int64) []CancelResult {
+	return ws.CancelOrdersCtx(ws.Ctx, ids)
+}
+
+// CreateOrdersCtx places orders and returns their results in input order.
+// Orders failing validation are not sent; with a balance check, each order
+// is checked against what the orders before it left. A batch call that fails as a
+// whole fails every order it carried.
+func (ws *wonService) CreateOrdersCtx(ctx context.Context, orders []CreateOrderRequest) []OrderResult {
+	results := make([]OrderResult, len(orders))
+	prepared, errs := ws.prepareOrders(ctx, orders)
+	var pending []int
+	for i, err := range errs {
+		if err != nil {
+			results[i].Err = err
+			continue
+		}
+		pending = append(pending, i)
+	}
+
+	if ws.batch.enabled() {
+		for start := 0; start < len(pending); start += ws.batch.size {
+			end := start + ws.batch.size
+			if end > len(pending) {
+				end = len(pending)
+			}
+			chunk := pending[start:end]
+			err := ws.createBatch(ctx, prepared, chunk, results)
+			if err == nil {
+				continue
+			}
+			if ws.batch.fallback(err) {
+				pending = pending[start:]
+				break
+			}
+			for _, i := range chunk {
+				results[i].Err = err
+			}
+		}
+		if ws.batch.enabled() {
+			return results
+		}
+	}
+
+	ws.fanOut(len(pending), func(n int) {
+		i := pending[n]
+		results[i].Order, results[i].Err = ws.sendOrder(ctx, prepared[i])
+	})
+	return results
+}
+
+// CancelOrdersCtx cancels the orders with ids and returns the results in
+// input order.
+func (ws *wonService) CancelOrdersCtx(ctx context.Context, ids []int64) []CancelResult {
+	results := make([]CancelResult, len(ids))
+	pending := make([]int, len(ids))
+	for i, id := range ids {
+		results[i].Id = id
+		pending[i] = i
+	}
+
+	if ws.batch.enabled() {
+		for start := 0; start < len(pending); start += ws.batch.size {
+			end := start + ws.batch.size
+			if end > len(pending) {
+				end = len(pending)
+			}
+			chunk := pending[start:end]
+			err := ws.cancelBatch(ctx, chunk, results)
+			if err == nil {
+				continue
+			}
+			if ws.batch.fallback(err) {
+				pending = pending[start:]
+				break
+			}
+			for _, i := range chunk {
+				results[i].Err = err
+			}
+		}
+		if ws.batch.enabled() {
+			return results
+		}
+	}
+
+	ws.fanOut(len(pending), func(n int) {
+		i := pending[n]
+		results[i].Err = ws.CancelOrderCtx(ctx, CancelOrderRequest{Id: ids[i]})
+	})
+	return results
+}
+
+// fanOut runs f(0) to f(n-1) on up to the configured number of goroutines.
+func (ws *wonService) fanOut(n int, f func(int)) {
+	concurrency := ws.batch.concurrency
+	if concurrency <= 0 {
+		concurrency = DefaultBatchConcurrency
+	}
+	sem := make(chan struct{}, concurrency)
+	var wg sync.WaitGroup
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(i int) {
+			defer wg.Done()
+			defer func() { <-sem }()
+			f(i)
+		}(i)
+	}
+	wg.Wait()
+}
+
+// batchItem is one entry of a batch response: the order or cancellation, or
+// the reason it was refused.
+type batchItem struct {
+	Order
+	Result  string `json:"result"`
+	Code    string `json:"error"`
+	Message string `json:"error_description"`
+}
+
+func (item batchItem) err(endpoint string) error {
+	if item.Code == "" {
+		return nil
+	}
+	return &WonError{StatusCode: http.StatusOK, Path: endpoint, Code: item.Code, Message: item.Message}
+}
+
+func (ws *wonService) createBatch(ctx context.Context, orders []CreateOrderRequest, chunk []int, results []OrderResult) error {
+	const endpoint = "api/v1/orders/create_batch"
+	batch := make([]map[string]string, len(chunk))
+	for n, i := range chunk {
+		batch[n] = orderParams(orders[i])
+	}
+	raw, err := json.Marshal(batch)
+	if err != nil {
+		return err
+	}
+	params := map[string]string{"orders": string(raw)}
+	ws.stamp(params, 0, 0)
+
+	var items []batchItem
+	if err := ws.call(ctx, "POST", endpoint, params, true, true, &items); err != nil {
+		return err
+	}
+	if len(items) != len(chunk) {
+		return errors.New(fmt.Sprintf("%s returned %d results for %d orders", endpoint, len(items), len(chunk)))
+	}
+	for n, i := range chunk {
+		if results[i].Err = items[n].err(endpoint); results[i].Err != nil {
+			continue
+		}
+		order := items[n].Order
+		if order.ClientOrderId == "" {
+			order.ClientOrderId = orders[i].ClientOrderId
+		}
+		results[i].Order = &order
+	}
+	return nil
+}
+
+func (ws *wonService) cancelBatch(ctx context.Context, chunk []int, results []CancelResult) error {
+	const endpoint = "api/v1/orders/cancel_batch"
+	ids := make([]string, len(chunk))
+	for n, i := range chunk {
+		ids[n] = strconv.FormatInt(results[i].Id, 10)
+	}
+	params := map[string]string{"ids": strings.Join(ids, ",")}
+	ws.stamp(params, 0, 0)
+
+	var items []batchItem
+	if err := ws.call(ctx, "POST", endpoint, params, true, true, &items); err != nil {
+		return err
+	}
+	if len(items) != len(chunk) {
+		return errors.New(fmt.Sprintf("%s returned %d results for %d orders", endpoint, len(items), len(chunk)))
+	}
+	for n, i := range chunk {
+		results[i].Err = items[n].err(endpoint)
+		if results[i].Err == nil && items[n].Result != "success" {
+			results[i].Err = errors.New(fmt.Sprintf("CancelOrder unexpected result:%s", items[n].Result))
+		}
+	}
+	return nil
+}
@@ -0,0 +1,131 @@
+package pkg
+
+import (
+	"context"
+	"errors"
+)
+
+// maxCancelRounds bounds how many times CancelWhere lists the open orders
+// again to catch orders placed meanwhile and retry failed cancels.
+const maxCancelRounds = 10
+
+// ErrCancelIncomplete is returned with the report by CancelAll and
+// CancelWhere when matching orders were still open after the last round.
+var ErrCancelIncomplete = errors.New("open orders remain after cancelling")
+
+// CancelReport is the outcome of CancelAll and CancelWhere.
+type CancelReport struct {
+	// Cancelled holds the orders now cancelled, by this call or before it.
+	Cancelled []*Order
+	// Filled holds the orders that filled before they could be cancelled.
+	Filled []*Order
+	Failed []CancelFailure
+}
+
+// CancelFailure is an order that may still be open.
+type CancelFailure struct {
+	Order *Order
+	Err   error
+}
+
+func (ws *wonService) CancelAll(market string, side Side) (*CancelReport, error) {
+	return ws.CancelAllCtx(ws.Ctx, market, side)
+}
+func (ws *wonService) CancelWhere(match func(*Order) bool) (*CancelReport, error) {
+	return ws.CancelWhereCtx(ws.Ctx, match)
+}
+
+// CancelAllCtx cancels every open order of market on side. An empty market
+// or side matches all of them. It fails with ErrCancelIncomplete when some
+// remain open.
+func (ws *wonService) CancelAllCtx(ctx context.Context, market string, side Side) (*CancelReport, error) {
+	return ws.cancelOpen(ctx, OrdersRequest{Market: market, Side: side, State: StateWait}, func(*Order) bool { return true })
+}
+
+// CancelWhereCtx cancels every open order, in any market, for which match
+// returns true.
+func (ws *wonService) CancelWhereCtx(ctx context.Context, match func(*Order) bool) (*CancelReport, error) {
+	return ws.cancelOpen(ctx, OrdersRequest{State: StateWait}, match)
+}
+
+// cancelOpen lists every open order selected by osr and cancels the
+// matching ones concurrently, listing again until none is left. Failed
+// cancels are retried on the next round and only the last failure of each
+// order is reported. The report covers what was done before any error.
+func (ws *wonService) cancelOpen(ctx context.Context, osr OrdersRequest, match func(*Order) bool) (*CancelReport, error) {
+	report := &CancelReport{}
+	// done holds the orders cancelled or filled, which a lagging listing may
+	// still return as open.
+	done := make(map[int64]bool)
+	for round := 0; round < maxCancelRounds; round++ {
+		var orders []*Order
+		var ids []int64
+		it := NewOrdersIterator(ctx, ws, osr)
+		for it.Next() {
+			if o := it.Order(); !done[o.Id] && match(o) {
+				orders = append(orders, o)
+				ids = append(ids, o.Id)
+			}
+		}
+		if err := it.Err(); err != nil {
+			return report, err
+		}
+		if len(ids) == 0 {
+			return report, nil
+		}
+		report.Failed = withoutOrders(report.Failed, ids)
+		for i, result := range ws.CancelOrdersCtx(ctx, ids) {
+			ws.classifyCancel(ctx, report, orders[i], result.Err)
+		}
+		for _, id := range ids {
+			done[id] = true
+		}
+		for _, f := range report.Failed {
+			delete(done, f.Order.Id)
+		}
+	}
+	return report, ErrCancelIncomplete
+}
+
+// withoutOrders returns failed without the failures of the orders in ids.
+func withoutOrders(failed []CancelFailure, ids []int64) []CancelFailure {
+	retried := make(map[int64]bool, len(ids))
+	for _, id := range ids {
+		retried[id] = true
+	}
+	var kept []CancelFailure
+	for _, f := range failed {
+		if !retried[f.Order.Id] {
+			kept = append(kept, f)
+		}
+	}
+	return kept
+}
+
+// classifyCancel files order in report. A failed cancel is checked against
+// the current state of the order, as it may have filled or been cancelled in
+// the meantime.
+func (ws *wonService) classifyCancel(ctx context.Context, report *CancelReport, order *Order, err error) {
+	if err == nil {
+		cancelled := *order
+		cancelled.State = StateCancel
+		report.Cancelled = append(report.Cancelled, &cancelled)
+		return
+	}
+	current, getErr := ws.GetOrderCtx(ctx, OrderRequest{Id: order.Id})
+	if getErr != nil {
+		if !errors.Is(getErr, ErrOrderNotFound) {
+			err = getErr
+		}
+		report.Failed = append(report.Failed, CancelFailure{Order: order, Err: err})
+		return
+	}
+	switch current.State {
+	case StateDone:
+		report.Filled = append(report.Filled, current)
+	case StateCancel, StateReject:
+		report.Cancelled = append(report.Cancelled, current)
+	default:
+		report.Failed = append(report.Failed, CancelFailure{Order: current, Err: err})
+	}
+}
@@ -0,0 +1,177 @@
+package pkg
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+
+	"github.com/go-kit/kit/log/level"
+)
+
+// SigningScheme defines what a signed request is signed over and where the
+// signature goes.
+type SigningScheme int
+
+const (
+	// SignQuery signs the encoded request parameters, the query string or
+	// the body, and sends the signature as one more parameter. It is the
+	// default and what the exchange has always accepted.
+	SignQuery SigningScheme = iota
+	// SignCanonical signs the canonical request, see SignPayload.Canonical,
+	// and sends the signature in the X-Won-Signature header.
+	SignCanonical
+)
+
+// BodyEncoding is how POST requests carry their parameters. GET requests
+// always use the query string.
+type BodyEncoding int
+
+const (
+	// BodyNone sends the parameters in the query string, with no body.
+	BodyNone BodyEncoding = iota
+	// BodyForm sends them as an application/x-www-form-urlencoded body.
+	BodyForm
+	// BodyJSON sends them as a JSON object of strings.
+	BodyJSON
+)
+
+// WithSigningScheme sets what signed requests are signed over.
+func WithSigningScheme(scheme SigningScheme) Option {
+	return func(ws *wonService) {
+		ws.signing = scheme
+	}
+}
+
+// WithBodyEncoding makes POST requests send their parameters in the body.
+func WithBodyEncoding(encoding BodyEncoding) Option {
+	return func(ws *wonService) {
+		ws.body = encoding
+	}
+}
+
+// SignPayload is everything a signed request is signed over.
+type SignPayload struct {
+	Method string
+	// Path is the escaped URL path, such as /api/v1/order.
+	Path string
+	// Query is the encoded query string, sorted by key, without the
+	// signature.
+	Query string
+	// Body is the request body as sent, without the signature.
+	Body      []byte
+	Timestamp string
+	// APIKey is the X-Won-Apikey header, empty if not sent.
+	APIKey string
+}
+
+// Canonical returns the bytes signed under scheme. For SignQuery they are
+// the query string followed by the body, one of which is empty. For
+// SignCanonical they are the method, path, query, timestamp and api key,
+// each followed by a newline, then the body.
+func (p SignPayload) Canonical(scheme SigningScheme) []byte {
+	var buf bytes.Buffer
+	if scheme == SignCanonical {
+		for _, s := range []string{p.Method, p.Path, p.Query, p.Timestamp, p.APIKey} {
+			buf.WriteString(s)
+			buf.WriteByte('\n')
+		}
+	} else {
+		buf.WriteString(p.Query)
+	}
+	buf.Write(p.Body)
+	return buf.Bytes()
+}
+
+// newRequest builds the request of a call, signed if sign.
+func (ws *wonService) newRequest(ctx context.Context, method string, endpoint string, params map[string]string,
+	apiKey bool, sign bool) (*http.Request, error) {
+	u, err := url.Parse(fmt.Sprintf("%s/%s", ws.URL, endpoint))
+	if err != nil {
+		return nil, errors.New(fmt.Sprintf("create request error:%s", err.Error()))
+	}
+	values := u.Query()
+	for key, val := range params {
+		values.Set(key, val)
+	}
+	encoding := ws.body
+	if method == http.MethodGet {
+		encoding = BodyNone
+	}
+
+	payload := SignPayload{Method: method, Path: u.EscapedPath(), Timestamp: params["timestamp"]}
+	if apiKey {
+		payload.APIKey = ws.APIKey
+	}
+	encode := func() error {
+		switch encoding {
+		case BodyForm:
+			payload.Body = []byte(values.Encode())
+		case BodyJSON:
+			object := make(map[string]string, len(values))
+			for key := range values {
+				object[key] = values.Get(key)
+			}
+			// Maps marshal with sorted keys, so the body is canonical too.
+			body, err := json.Marshal(object)
+			if err != nil {
+				return err
+			}
+			payload.Body = body
+		default:
+			payload.Query = values.Encode()
+		}
+		return nil
+	}
+	if err := encode(); err != nil {
+		return nil, err
+	}
+
+	var signature string
+	if sign {
+		if ws.Signer == nil {
+			return nil, errors.New(fmt.Sprintf("%s requires a signer", endpoint))
+		}
+		signed := payload.Canonical(ws.signing)
+		level.Debug(ws.Logger).Log("signPayload", string(signed))
+		signature = ws.Signer.Sign(signed)
+		level.Debug(ws.Logger).Log("signature", signature)
+		if ws.signing == SignQuery {
+			values.Set("signature", signature)
+			if err := encode(); err != nil {
+				return nil, err
+			}
+		}
+	}
+	u.RawQuery = payload.Query
+
+	var body io.Reader
+	if encoding != BodyNone {
+		body = bytes.NewReader(payload.Body)
+	}
+	req, err := http.NewRequest(method, u.String(), body)
+	if err != nil {
+		return nil, errors.New(fmt.Sprintf("create request error:%s", err.Error()))
+	}
+	req = req.WithContext(ctx)
+	switch encoding {
+	case BodyForm:
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	case BodyJSON:
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if apiKey {
+		req.Header.Add("X-Won-Apikey", ws.APIKey)
+	}
+	if ws.UserAgent != "" {
+		req.Header.Set("User-Agent", ws.UserAgent)
+	}
+	if sign && ws.signing == SignCanonical {
+		req.Header.Set("X-Won-Signature", signature)
+	}
+	return req, nil
+}
@@ -0,0 +1,141 @@
+package pkg
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"net/http/httptrace"
+	"sync"
+	"time"
+
+	"github.com/go-kit/kit/log/level"
+)
+
+// NewClientOrderId returns a random client order id.
+func NewClientOrderId() string {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		// crypto/rand only fails when the system has no entropy source.
+		panic(err)
+	}
+	return hex.EncodeToString(b)
+}
+
+func (ws *wonService) CreateOrRecoverOrder(cor CreateOrderRequest) (*Order, error) {
+	return ws.CreateOrRecoverOrderCtx(ws.Ctx, cor)
+}
+
+// recoverTimeout bounds the order lookup made after the caller's ctx ended
+// with the create call in flight.
+const recoverTimeout = 5 * time.Second
+
+// CreateOrRecoverOrderCtx creates cor without ever placing it twice. Unless
+// the create call was rejected by the exchange or failed before the request
+// was fully sent, it is unknown whether the order landed: the open and
+// recent orders of the market are then searched for its client order id,
+// and the order is only sent again when it is not found. Attempts follow the
+// retry policy of the service. When ctx ends with the order unconfirmed the
+// lookup is still made, for at most recoverTimeout, but the order is not
+// sent again.
+func (ws *wonService) CreateOrRecoverOrderCtx(ctx context.Context, cor CreateOrderRequest) (*Order, error) {
+	cor, err := ws.prepareOrder(ctx, cor)
+	if err != nil {
+		return nil, err
+	}
+	attempts := ws.Retry.MaxAttempts
+	if attempts < 1 {
+		attempts = 1
+	}
+	for attempt := 0; ; attempt++ {
+		var sent sendTrace
+		order, err := ws.sendOrder(sent.attach(ctx), cor)
+		if err == nil {
+			return order, nil
+		}
+		if rejected(err) || sent.unsent() {
+			return nil, err
+		}
+		lookupCtx := ctx
+		if ctx.Err() != nil {
+			var cancel context.CancelFunc
+			lookupCtx, cancel = context.WithTimeout(context.Background(), recoverTimeout)
+			defer cancel()
+		}
+		order, found, lookupErr := ws.findClientOrder(lookupCtx, cor.Market, cor.ClientOrderId)
+		if lookupErr != nil {
+			// Sending again could place the order twice.
+			level.Warn(ws.Logger).Log("msg", "order recovery failed", "clientOrderId", cor.ClientOrderId, "err", lookupErr)
+			return nil, err
+		}
+		if found {
+			return order, nil
+		}
+		if attempt+1 >= attempts || ctx.Err() != nil {
+			return nil, err
+		}
+		var retryAfter time.Duration
+		if e, ok := err.(*WonError); ok {
+			retryAfter = e.RetryAfter
+		}
+		delay := ws.Retry.backoff(attempt, retryAfter)
+		level.Debug(ws.Logger).Log("clientOrderId", cor.ClientOrderId, "attempt", attempt+1, "retryIn", delay, "err", err)
+		select {
+		case <-ctx.Done():
+			return nil, err
+		case <-time.After(delay):
+		}
+	}
+}
+
+// rejected reports whether err is an answer of the exchange refusing the
+// order, as opposed to a failure that may have let it through.
+func rejected(err error) bool {
+	e, ok := err.(*WonError)
+	return ok && e.StatusCode < 500
+}
+
+// sendTrace records whether a request got as far as being fully written.
+type sendTrace struct {
+	mu      sync.Mutex
+	started bool
+	written bool
+}
+
+func (t *sendTrace) attach(ctx context.Context) context.Context {
+	return httptrace.WithClientTrace(ctx, &httptrace.ClientTrace{
+		GetConn: func(string) {
+			t.mu.Lock()
+			t.started = true
+			t.mu.Unlock()
+		},
+		WroteRequest: func(info httptrace.WroteRequestInfo) {
+			t.mu.Lock()
+			t.written = t.written || info.Err == nil
+			t.mu.Unlock()
+		},
+	})
+}
+
+// unsent reports whether the request certainly never reached the exchange:
+// it was traced from the start and never written in full. Transports that
+// do not report to the trace count as having sent it.
+func (t *sendTrace) unsent() bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.started && !t.written
+}
+
+// findClientOrder looks for the order with clientOrderId among the latest
+// orders of market, in any state.
+func (ws *wonService) findClientOrder(ctx context.Context, market, clientOrderId string) (*Order, bool, error) {
+	orders, err := ws.GetOrdersCtx(ctx, OrdersRequest{Market: market})
+	if err != nil {
+		return nil, false, err
+	}
+	for _, o := range orders {
+		if o.ClientOrderId == clientOrderId {
+			return o, true, nil
+		}
+	}
+	return nil, false, nil
+}
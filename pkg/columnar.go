@@ -0,0 +1,205 @@
+package pkg
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// The columnar format starts with columnarMagic and the column names, then
+// holds blocks of up to columnarBlockRows records. A block is its row count
+// followed by the values of each column in turn. Counts, lengths and names
+// are uvarint length prefixed, values are raw strings. Blocks may be appended
+// to a file at any time.
+const (
+	columnarMagic     = "WONCOL1\n"
+	columnarBlockRows = 1024
+	// ReadColumnar rejects files declaring more columns or longer strings
+	// than these, rather than allocating what a corrupt count asks for.
+	columnarMaxColumns = 1024
+	columnarMaxString  = 1 << 20
+)
+
+type columnarWriter struct {
+	w       io.Writer
+	columns [][]string
+	rows    int
+}
+
+// newColumnarWriter returns a writer of columns on w. When appending, w must
+// also be an io.ReadSeeker so that the header of the file can be checked
+// against columns; an empty file gets a new header.
+func newColumnarWriter(w io.Writer, columns []string, appending bool) (*columnarWriter, error) {
+	cw := &columnarWriter{w: w, columns: make([][]string, len(columns))}
+	if appending {
+		existing, err := columnarHeader(w)
+		if err != nil {
+			return nil, err
+		}
+		if existing != nil {
+			if !equalColumns(existing, columns) {
+				return nil, errors.New(fmt.Sprintf("columnar file has columns %v, want %v", existing, columns))
+			}
+			return cw, nil
+		}
+	}
+	buf := []byte(columnarMagic)
+	buf = appendUvarint(buf, uint64(len(columns)))
+	for _, c := range columns {
+		buf = appendString(buf, c)
+	}
+	if _, err := w.Write(buf); err != nil {
+		return nil, err
+	}
+	return cw, nil
+}
+
+// columnarHeader returns the column names of the file w appends to, or nil
+// when it is empty, and leaves w at its end.
+func columnarHeader(w io.Writer) ([]string, error) {
+	rs, ok := w.(io.ReadSeeker)
+	if !ok {
+		return nil, errors.New("appending to a columnar file needs a readable and seekable writer")
+	}
+	size, err := rs.Seek(0, io.SeekEnd)
+	if err != nil || size == 0 {
+		return nil, err
+	}
+	if _, err := rs.Seek(0, io.SeekStart); err != nil {
+		return nil, err
+	}
+	columns, err := readColumnarHeader(bufio.NewReader(rs))
+	if err != nil {
+		return nil, err
+	}
+	if _, err := rs.Seek(0, io.SeekEnd); err != nil {
+		return nil, err
+	}
+	return columns, nil
+}
+
+func equalColumns(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func (cw *columnarWriter) Write(record []string) error {
+	if len(record) != len(cw.columns) {
+		return errors.New(fmt.Sprintf("record has %d columns, want %d", len(record), len(cw.columns)))
+	}
+	for i, v := range record {
+		cw.columns[i] = append(cw.columns[i], v)
+	}
+	cw.rows++
+	if cw.rows >= columnarBlockRows {
+		return cw.Flush()
+	}
+	return nil
+}
+
+// Flush writes the buffered records as one block.
+func (cw *columnarWriter) Flush() error {
+	if cw.rows == 0 {
+		return nil
+	}
+	buf := appendUvarint(nil, uint64(cw.rows))
+	for i, col := range cw.columns {
+		for _, v := range col {
+			buf = appendString(buf, v)
+		}
+		cw.columns[i] = col[:0]
+	}
+	cw.rows = 0
+	_, err := cw.w.Write(buf)
+	return err
+}
+
+func appendUvarint(buf []byte, v uint64) []byte {
+	var tmp [binary.MaxVarintLen64]byte
+	return append(buf, tmp[:binary.PutUvarint(tmp[:], v)]...)
+}
+
+func appendString(buf []byte, s string) []byte {
+	return append(appendUvarint(buf, uint64(len(s))), s...)
+}
+
+// ReadColumnar reads a file written in FormatColumnar and returns its column
+// names and records.
+func ReadColumnar(r io.Reader) ([]string, [][]string, error) {
+	br := bufio.NewReader(r)
+	columns, err := readColumnarHeader(br)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var records [][]string
+	for {
+		rows, err := binary.ReadUvarint(br)
+		if err == io.EOF {
+			return columns, records, nil
+		}
+		if err != nil {
+			return nil, nil, err
+		}
+		if rows > columnarBlockRows {
+			return nil, nil, errors.New(fmt.Sprintf("columnar block of %d rows exceeds %d", rows, columnarBlockRows))
+		}
+		block := make([][]string, rows)
+		for i := range block {
+			block[i] = make([]string, len(columns))
+		}
+		for c := range columns {
+			for i := range block {
+				if block[i][c], err = readString(br); err != nil {
+					return nil, nil, errors.New(fmt.Sprintf("truncated columnar block:%s", err.Error()))
+				}
+			}
+		}
+		records = append(records, block...)
+	}
+}
+
+func readColumnarHeader(br *bufio.Reader) ([]string, error) {
+	magic := make([]byte, len(columnarMagic))
+	if _, err := io.ReadFull(br, magic); err != nil || string(magic) != columnarMagic {
+		return nil, errors.New("not a columnar export file")
+	}
+	n, err := binary.ReadUvarint(br)
+	if err != nil {
+		return nil, err
+	}
+	if n > columnarMaxColumns {
+		return nil, errors.New(fmt.Sprintf("columnar file of %d columns exceeds %d", n, columnarMaxColumns))
+	}
+	columns := make([]string, n)
+	for i := range columns {
+		if columns[i], err = readString(br); err != nil {
+			return nil, err
+		}
+	}
+	return columns, nil
+}
+
+func readString(br *bufio.Reader) (string, error) {
+	n, err := binary.ReadUvarint(br)
+	if err != nil {
+		return "", err
+	}
+	if n > columnarMaxString {
+		return "", errors.New(fmt.Sprintf("columnar string of %d bytes exceeds %d", n, columnarMaxString))
+	}
+	b := make([]byte, n)
+	if _, err := io.ReadFull(br, b); err != nil {
+		return "", err
+	}
+	return string(b), nil
+}
@@ -0,0 +1,340 @@
+package pkg
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"strconv"
+	"strings"
+)
+
+// RoundingMode selects how Round, Div and RoundToStep drop digits.
+type RoundingMode int
+
+const (
+	// RoundDown rounds towards zero.
+	RoundDown RoundingMode = iota
+	// RoundUp rounds away from zero.
+	RoundUp
+	// RoundHalfUp rounds to the nearest value, ties away from zero.
+	RoundHalfUp
+	// RoundHalfEven rounds to the nearest value, ties to the even digit.
+	RoundHalfEven
+)
+
+// Decimal is an exact, arbitrary-precision decimal number such as a price,
+// a volume or a balance. It keeps the string form it was parsed from, so
+// values received from the exchange are sent back unchanged, and it is
+// comparable with == on that form; use Cmp or Equal to compare numerically.
+// The zero value is 0.
+type Decimal struct {
+	s string
+}
+
+var ten = big.NewInt(10)
+
+// pow10 returns 10^n. n must not be negative.
+func pow10(n int32) *big.Int {
+	return new(big.Int).Exp(ten, big.NewInt(int64(n)), nil)
+}
+
+// NewDecimal parses s, written as in "-12.345" or "1.5e-8".
+func NewDecimal(s string) (Decimal, error) {
+	unscaled, scale, ok := parseDecimal(s)
+	if !ok {
+		return Decimal{}, errors.New(fmt.Sprintf("invalid decimal:%q", s))
+	}
+	if strings.ContainsAny(s, "eE+") || strings.HasPrefix(strings.TrimPrefix(s, "-"), ".") || strings.HasSuffix(s, ".") {
+		return newDecimal(unscaled, scale), nil
+	}
+	return Decimal{s: s}, nil
+}
+
+// MustDecimal is like NewDecimal but panics when s is not a decimal.
+func MustDecimal(s string) Decimal {
+	d, err := NewDecimal(s)
+	if err != nil {
+		panic(err)
+	}
+	return d
+}
+
+func NewDecimalFromInt(i int64) Decimal {
+	return Decimal{s: strconv.FormatInt(i, 10)}
+}
+
+// NewDecimalFromFloat returns the shortest decimal that parses back to f.
+func NewDecimalFromFloat(f float64) Decimal {
+	return MustDecimal(strconv.FormatFloat(f, 'f', -1, 64))
+}
+
+// maxExponent bounds the exponent NewDecimal accepts, so that "1e999999999"
+// cannot make it allocate a billion digits.
+const maxExponent = 1000
+
+func parseDecimal(s string) (*big.Int, int32, bool) {
+	mantissa, exp := s, int64(0)
+	if i := strings.IndexAny(s, "eE"); i >= 0 {
+		var err error
+		if exp, err = strconv.ParseInt(s[i+1:], 10, 32); err != nil {
+			return nil, 0, false
+		}
+		if exp > maxExponent || exp < -maxExponent {
+			return nil, 0, false
+		}
+		mantissa = s[:i]
+	}
+	neg := false
+	if len(mantissa) > 0 && (mantissa[0] == '-' || mantissa[0] == '+') {
+		neg = mantissa[0] == '-'
+		mantissa = mantissa[1:]
+	}
+	intPart, fracPart := mantissa, ""
+	if i := strings.IndexByte(mantissa, '.'); i >= 0 {
+		intPart, fracPart = mantissa[:i], mantissa[i+1:]
+	}
+	digits := intPart + fracPart
+	if digits == "" || strings.Trim(digits, "0123456789") != "" {
+		return nil, 0, false
+	}
+	unscaled, _ := new(big.Int).SetString(digits, 10)
+	if neg {
+		unscaled.Neg(unscaled)
+	}
+	scale := int64(len(fracPart)) - exp
+	if scale < 0 {
+		unscaled.Mul(unscaled, pow10(int32(-scale)))
+		scale = 0
+	}
+	return unscaled, int32(scale), true
+}
+
+// newDecimal formats unscaled * 10^-scale.
+func newDecimal(unscaled *big.Int, scale int32) Decimal {
+	digits := new(big.Int).Abs(unscaled).String()
+	if scale > 0 {
+		if pad := int(scale) + 1 - len(digits); pad > 0 {
+			digits = strings.Repeat("0", pad) + digits
+		}
+		digits = digits[:len(digits)-int(scale)] + "." + digits[len(digits)-int(scale):]
+	}
+	if unscaled.Sign() < 0 {
+		digits = "-" + digits
+	}
+	return Decimal{s: digits}
+}
+
+func (d Decimal) parts() (*big.Int, int32) {
+	if d.s == "" {
+		return new(big.Int), 0
+	}
+	unscaled, scale, _ := parseDecimal(d.s)
+	return unscaled, scale
+}
+
+// String returns the decimal as it was parsed or computed.
+func (d Decimal) String() string {
+	if d.s == "" {
+		return "0"
+	}
+	return d.s
+}
+
+// Rat returns the decimal as an exact rational.
+func (d Decimal) Rat() *big.Rat {
+	unscaled, scale := d.parts()
+	return new(big.Rat).SetFrac(unscaled, pow10(scale))
+}
+
+// Float64 returns the nearest float64; it may lose precision.
+func (d Decimal) Float64() float64 {
+	f, _ := strconv.ParseFloat(d.String(), 64)
+	return f
+}
+
+// Scale returns the number of digits after the decimal point.
+func (d Decimal) Scale() int32 {
+	_, scale := d.parts()
+	return scale
+}
+
+func (d Decimal) Sign() int {
+	unscaled, _ := d.parts()
+	return unscaled.Sign()
+}
+
+func (d Decimal) IsZero() bool {
+	return d.Sign() == 0
+}
+
+// Cmp returns -1, 0 or +1 depending on whether d is less than, equal to or
+// greater than e.
+func (d Decimal) Cmp(e Decimal) int {
+	a, b := align(d, e)
+	return a.Cmp(b)
+}
+
+// Equal reports whether d and e are numerically equal, as 1.5 and 1.50 are.
+func (d Decimal) Equal(e Decimal) bool {
+	return d.Cmp(e) == 0
+}
+
+// align returns the unscaled values of d and e at their common scale.
+func align(d, e Decimal) (*big.Int, *big.Int) {
+	a, sa := d.parts()
+	b, sb := e.parts()
+	if sa < sb {
+		a.Mul(a, pow10(sb-sa))
+	} else if sb < sa {
+		b.Mul(b, pow10(sa-sb))
+	}
+	return a, b
+}
+
+func maxScale(d, e Decimal) int32 {
+	sa, sb := d.Scale(), e.Scale()
+	if sa > sb {
+		return sa
+	}
+	return sb
+}
+
+func (d Decimal) Add(e Decimal) Decimal {
+	a, b := align(d, e)
+	return newDecimal(a.Add(a, b), maxScale(d, e))
+}
+
+func (d Decimal) Sub(e Decimal) Decimal {
+	a, b := align(d, e)
+	return newDecimal(a.Sub(a, b), maxScale(d, e))
+}
+
+func (d Decimal) Mul(e Decimal) Decimal {
+	a, sa := d.parts()
+	b, sb := e.parts()
+	return newDecimal(a.Mul(a, b), sa+sb)
+}
+
+func (d Decimal) Neg() Decimal {
+	unscaled, scale := d.parts()
+	return newDecimal(unscaled.Neg(unscaled), scale)
+}
+
+func (d Decimal) Abs() Decimal {
+	if d.Sign() < 0 {
+		return d.Neg()
+	}
+	return d
+}
+
+// Div returns d / e rounded to scale digits after the decimal point, or to
+// a multiple of 10^-scale when scale is negative. It panics when e is zero.
+func (d Decimal) Div(e Decimal, scale int32, mode RoundingMode) Decimal {
+	if e.IsZero() {
+		panic("decimal division by zero")
+	}
+	return roundRat(new(big.Rat).Quo(d.Rat(), e.Rat()), scale, mode)
+}
+
+// Round rounds d to scale digits after the decimal point. A negative scale
+// rounds to tens, hundreds and so on: 1234.5 rounds to 1200 at scale -2.
+// Decimals that already have no more digits are returned unchanged.
+func (d Decimal) Round(scale int32, mode RoundingMode) Decimal {
+	if d.Scale() <= scale {
+		return d
+	}
+	return roundRat(d.Rat(), scale, mode)
+}
+
+// Truncate drops the digits after scale, as Round with RoundDown.
+func (d Decimal) Truncate(scale int32) Decimal {
+	return d.Round(scale, RoundDown)
+}
+
+// RoundToStep rounds d to a multiple of step, such as a price tick or a lot
+// size. A zero step returns d unchanged.
+func (d Decimal) RoundToStep(step Decimal, mode RoundingMode) Decimal {
+	if step.IsZero() {
+		return d
+	}
+	steps := roundRat(new(big.Rat).Quo(d.Rat(), step.Rat()), 0, mode)
+	return steps.Mul(step)
+}
+
+// Canonical returns d without trailing fractional zeros, as in 1.5 for 1.500.
+func (d Decimal) Canonical() Decimal {
+	s := d.String()
+	if strings.IndexByte(s, '.') < 0 {
+		return d
+	}
+	s = strings.TrimRight(strings.TrimRight(s, "0"), ".")
+	if s == "-0" {
+		s = "0"
+	}
+	return Decimal{s: s}
+}
+
+// roundRat rounds r to scale digits after the decimal point, or to a
+// multiple of 10^-scale when scale is negative.
+func roundRat(r *big.Rat, scale int32, mode RoundingMode) Decimal {
+	num, den := new(big.Int).Set(r.Num()), new(big.Int).Set(r.Denom())
+	if scale >= 0 {
+		num.Mul(num, pow10(scale))
+	} else {
+		den.Mul(den, pow10(-scale))
+	}
+	q, rem := new(big.Int).QuoRem(num, den, new(big.Int))
+	if rem.Sign() != 0 {
+		away := false
+		switch mode {
+		case RoundUp:
+			away = true
+		case RoundHalfUp, RoundHalfEven:
+			twice := new(big.Int).Abs(rem)
+			twice.Lsh(twice, 1)
+			c := twice.Cmp(den)
+			away = c > 0 || (c == 0 && (mode == RoundHalfUp || q.Bit(0) == 1))
+		}
+		if away {
+			if num.Sign() < 0 {
+				q.Sub(q, big.NewInt(1))
+			} else {
+				q.Add(q, big.NewInt(1))
+			}
+		}
+	}
+	if scale < 0 {
+		return newDecimal(q.Mul(q, pow10(-scale)), 0)
+	}
+	return newDecimal(q, scale)
+}
+
+// MarshalJSON encodes d as a JSON string, the form used by the exchange.
+func (d Decimal) MarshalJSON() ([]byte, error) {
+	return json.Marshal(d.String())
+}
+
+// UnmarshalJSON accepts a JSON string or number. null and "" decode to 0.
+func (d *Decimal) UnmarshalJSON(data []byte) error {
+	s := string(data)
+	if s == "null" {
+		*d = Decimal{}
+		return nil
+	}
+	if strings.HasPrefix(s, `"`) {
+		if err := json.Unmarshal(data, &s); err != nil {
+			return err
+		}
+		if s == "" {
+			*d = Decimal{}
+			return nil
+		}
+	}
+	v, err := NewDecimal(s)
+	if err != nil {
+		return err
+	}
+	*d = v
+	return nil
+}
@@ -20,20 +20,37 @@ type AccountRequest struct {
 	Timestamp  int64
 }
 
+// CreateOrderRequest describes a new order. It is best built with LimitOrder,
+// MarketOrder and the other order builders; see Check for the fields each
+// order type requires.
 type CreateOrderRequest struct {
-	Market     string
-	Side       string
-	Volume     string
-	Price      string
-	OrdType    string
-	RecvWindow int
-	Timestamp  int64
+	Market  string
+	Side    Side
+	Volume  Decimal
+	Price   Decimal
+	OrdType OrdType
+	// Funds is the quote amount to spend or receive with a market order,
+	// instead of a base Volume.
+	Funds Decimal
+	// StopPrice triggers stop-loss and take-profit orders.
+	StopPrice   Decimal
+	TimeInForce TimeInForce
+	// PostOnly rejects a limit order that would match on arrival, so that
+	// it only ever adds liquidity.
+	PostOnly bool
+	// ClientOrderId identifies the order across retries. CreateOrder
+	// generates one when it is empty.
+	ClientOrderId string
+	RecvWindow    int
+	Timestamp     int64
 }
 
+// OrderRequest selects an order by Id or, when Id is zero, by ClientOrderId.
 type OrderRequest struct {
-	Id         int64
-	RecvWindow int
-	Timestamp  int64
+	Id            int64
+	ClientOrderId string
+	RecvWindow    int
+	Timestamp     int64
 }
 
 type CancelOrderRequest struct {
@@ -45,8 +62,8 @@ type CancelOrderRequest struct {
 type OrdersRequest struct {
 	Market       string
 	OrderId      int64
-	State        string
-	Side         string
+	State        State
+	Side         Side
 	StartAtStamp int64
 	EndAtStamp   int64
 	Limit        int
@@ -56,65 +73,92 @@ type OrdersRequest struct {
 
 type DepthResult struct {
 	Time int
-	Bids []struct {
-		Price  string
-		Amount string
-	}
-	Asks []struct {
-		Price  string
-		Amount string
-	}
+	// LastUpdateId is the id of the last depth update included in the
+	// snapshot.
+	LastUpdateId int64
+	Bids         []PriceLevel
+	Asks         []PriceLevel
+}
+
+// PriceLevel is a price and the amount resting at it. An amount of zero in a
+// DepthUpdate removes the level.
+type PriceLevel struct {
+	Price  Decimal
+	Amount Decimal
 }
 
 type RecentTrade struct {
 	Id       int64
-	Price    string
-	Quantity string
+	Price    Decimal
+	Quantity Decimal
 	CreateAt int64
 }
 
 type MyTrade struct {
 	Id       int64
 	OrderId  int64
-	Price    string
-	Quantity string
-	Side     string
+	Price    Decimal
+	Quantity Decimal
+	Side     Side
 	CreateAt int64
 }
 
 type CurrencyAccount struct {
 	Currency     string
-	TotalBalance string
-	Balance      string
-	Locked       string
-	UsdPrice     string
+	TotalBalance Decimal
+	Balance      Decimal
+	Locked       Decimal
+	UsdPrice     Decimal
 	Precision    int
 	Limits       struct {
-		MinimalTradeFee string
+		MinimalTradeFee Decimal
 	}
 }
 
 type Account struct {
 	Accounts      []CurrencyAccount
-	EqualTotalUsd string
+	EqualTotalUsd Decimal
 }
 type TickerPrice struct {
 	Market string
-	Price  string
+	Price  Decimal
 }
 
 type Order struct {
-	Id              int64  `json:"id"`
-	Side            string `json:"side"`
-	OrdType         string `json:"ord_type"`
-	Price           string `json:"price"`
-	State           string `json:"state"`
-	Market          string `json:"market"`
-	BidCurrency     string `json:"bid_currency"`
-	AskCurrency     string `json:"ask_currency"`
-	CreatedAtStamp  int64  `json:"created_at_stamp"`
-	Volume          string `json:"volume"`
-	RemainingVolume string `json:"remaining_volume"`
-	ExecutedRate    string `json:"executed_rate"`
-	Funds           string `json:"funds"`
+	Id              int64       `json:"id"`
+	ClientOrderId   string      `json:"client_order_id"`
+	Side            Side        `json:"side"`
+	OrdType         OrdType     `json:"ord_type"`
+	Price           Decimal     `json:"price"`
+	State           State       `json:"state"`
+	Market          string      `json:"market"`
+	BidCurrency     string      `json:"bid_currency"`
+	AskCurrency     string      `json:"ask_currency"`
+	CreatedAtStamp  int64       `json:"created_at_stamp"`
+	Volume          Decimal     `json:"volume"`
+	RemainingVolume Decimal     `json:"remaining_volume"`
+	ExecutedRate    Decimal     `json:"executed_rate"`
+	Funds           Decimal     `json:"funds"`
+	StopPrice       Decimal     `json:"stop_price"`
+	TimeInForce     TimeInForce `json:"time_in_force"`
+	PostOnly        bool        `json:"post_only"`
+}
+
+// MarketInfo holds the trading rules of a market. Zero limits are not
+// enforced by the exchange.
+type MarketInfo struct {
+	Market        string  `json:"market"`
+	BaseCurrency  string  `json:"base_currency"`
+	QuoteCurrency string  `json:"quote_currency"`
+	PriceTick     Decimal `json:"price_tick"`
+	VolumeStep    Decimal `json:"volume_step"`
+	MinVolume     Decimal `json:"min_volume"`
+	MaxVolume     Decimal `json:"max_volume"`
+	MinNotional   Decimal `json:"min_notional"`
+	Status        string  `json:"status"`
+}
+
+type ExchangeInfo struct {
+	Time    int64        `json:"time"`
+	Markets []MarketInfo `json:"markets"`
 }
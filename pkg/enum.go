@@ -0,0 +1,167 @@
+package pkg
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+)
+
+// Side is the direction of an order or a trade.
+type Side string
+
+const (
+	SideBuy  Side = "buy"
+	SideSell Side = "sell"
+)
+
+// OrdType is the type of an order.
+type OrdType string
+
+const (
+	OrdTypeLimit  OrdType = "limit"
+	OrdTypeMarket OrdType = "market"
+	// Stop orders rest untriggered until the last price crosses their stop
+	// price, then enter the book as a market or a limit order.
+	OrdTypeStopLoss        OrdType = "stop_loss"
+	OrdTypeStopLossLimit   OrdType = "stop_loss_limit"
+	OrdTypeTakeProfit      OrdType = "take_profit"
+	OrdTypeTakeProfitLimit OrdType = "take_profit_limit"
+)
+
+// TimeInForce is how long an order stays in the book.
+type TimeInForce string
+
+const (
+	// GTC orders rest until filled or cancelled.
+	GTC TimeInForce = "gtc"
+	// IOC orders fill what they can immediately and cancel the rest.
+	IOC TimeInForce = "ioc"
+	// FOK orders fill entirely and immediately, or not at all.
+	FOK TimeInForce = "fok"
+)
+
+// State is the state of an order.
+type State string
+
+const (
+	// StateWait is an order resting in the book.
+	StateWait State = "wait"
+	// StateDone is a fully filled order.
+	StateDone State = "done"
+	// StateCancel is an order cancelled before it was fully filled.
+	StateCancel State = "cancel"
+	// StateReject is an order the matching engine refused.
+	StateReject State = "reject"
+)
+
+func (s Side) Valid() bool {
+	return s == SideBuy || s == SideSell
+}
+
+func (t OrdType) Valid() bool {
+	switch t {
+	case OrdTypeLimit, OrdTypeMarket, OrdTypeStopLoss, OrdTypeStopLossLimit, OrdTypeTakeProfit, OrdTypeTakeProfitLimit:
+		return true
+	}
+	return false
+}
+
+// HasPrice reports whether orders of type t carry a limit price.
+func (t OrdType) HasPrice() bool {
+	return t == OrdTypeLimit || t == OrdTypeStopLossLimit || t == OrdTypeTakeProfitLimit
+}
+
+// IsStop reports whether orders of type t wait for a stop price.
+func (t OrdType) IsStop() bool {
+	return t == OrdTypeStopLoss || t == OrdTypeStopLossLimit || t == OrdTypeTakeProfit || t == OrdTypeTakeProfitLimit
+}
+
+func (tif TimeInForce) Valid() bool {
+	return tif == GTC || tif == IOC || tif == FOK
+}
+
+func (s State) Valid() bool {
+	return s == StateWait || s.IsTerminal()
+}
+
+// IsTerminal reports whether an order in state s can no longer change.
+func (s State) IsTerminal() bool {
+	return s == StateDone || s == StateCancel || s == StateReject
+}
+
+// ParseSide returns the Side named s, or an error for unknown sides.
+func ParseSide(s string) (Side, error) {
+	if !Side(s).Valid() {
+		return "", errors.New(fmt.Sprintf("unknown side:%q", s))
+	}
+	return Side(s), nil
+}
+
+// ParseOrdType returns the OrdType named s, or an error for unknown types.
+func ParseOrdType(s string) (OrdType, error) {
+	if !OrdType(s).Valid() {
+		return "", errors.New(fmt.Sprintf("unknown order type:%q", s))
+	}
+	return OrdType(s), nil
+}
+
+// ParseTimeInForce returns the TimeInForce named s, or an error for unknown
+// values.
+func ParseTimeInForce(s string) (TimeInForce, error) {
+	if !TimeInForce(s).Valid() {
+		return "", errors.New(fmt.Sprintf("unknown time in force:%q", s))
+	}
+	return TimeInForce(s), nil
+}
+
+// ParseState returns the State named s, or an error for unknown states.
+func ParseState(s string) (State, error) {
+	if !State(s).Valid() {
+		return "", errors.New(fmt.Sprintf("unknown order state:%q", s))
+	}
+	return State(s), nil
+}
+
+// unmarshalEnum decodes a JSON string with parse. null and "" decode to the
+// empty value, as they do for fields the exchange leaves out.
+func unmarshalEnum(data []byte, parse func(string) error) error {
+	var s string
+	if string(data) == "null" {
+		return nil
+	}
+	if err := json.Unmarshal(data, &s); err != nil {
+		return err
+	}
+	if s == "" {
+		return nil
+	}
+	return parse(s)
+}
+
+func (s *Side) UnmarshalJSON(data []byte) error {
+	return unmarshalEnum(data, func(v string) (err error) {
+		*s, err = ParseSide(v)
+		return
+	})
+}
+
+func (t *OrdType) UnmarshalJSON(data []byte) error {
+	return unmarshalEnum(data, func(v string) (err error) {
+		*t, err = ParseOrdType(v)
+		return
+	})
+}
+
+func (tif *TimeInForce) UnmarshalJSON(data []byte) error {
+	return unmarshalEnum(data, func(v string) (err error) {
+		*tif, err = ParseTimeInForce(v)
+		return
+	})
+}
+
+func (s *State) UnmarshalJSON(data []byte) error {
+	return unmarshalEnum(data, func(v string) (err error) {
+		*s, err = ParseState(v)
+		return
+	})
+}
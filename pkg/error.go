@@ -1,12 +1,93 @@
 package pkg
 
-import "fmt"
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// Sentinel causes a WonError can be matched against with errors.Is.
+var (
+	ErrInsufficientFunds    = errors.New("insufficient funds")
+	ErrInvalidSignature     = errors.New("invalid signature")
+	ErrTimestampOutOfWindow = errors.New("timestamp outside recv window")
+	ErrRateLimited          = errors.New("rate limited")
+	ErrOrderNotFound        = errors.New("order not found")
+	ErrUnknownMarket        = errors.New("unknown market")
+)
+
+// errorCauses maps the error codes returned by the exchange to their causes.
+var errorCauses = map[string]error{
+	"insufficient_balance":   ErrInsufficientFunds,
+	"insufficient_funds":     ErrInsufficientFunds,
+	"invalid_signature":      ErrInvalidSignature,
+	"signature_mismatch":     ErrInvalidSignature,
+	"invalid_timestamp":      ErrTimestampOutOfWindow,
+	"timestamp_out_of_range": ErrTimestampOutOfWindow,
+	"recv_window_exceeded":   ErrTimestampOutOfWindow,
+	"too_many_requests":      ErrRateLimited,
+	"rate_limit_exceeded":    ErrRateLimited,
+	"order_not_found":        ErrOrderNotFound,
+	"record_not_found":       ErrOrderNotFound,
+	"market_not_found":       ErrUnknownMarket,
+	"invalid_market":         ErrUnknownMarket,
+}
 
 type WonError struct {
-	Code    string `json:"error"`
-	Message string `json:"error_description"`
+	StatusCode int    `json:"-"`
+	Path       string `json:"-"`
+	Body       []byte `json:"-"`
+	// RetryAfter is the delay requested by the exchange's Retry-After
+	// header, if any.
+	RetryAfter time.Duration `json:"-"`
+	Code       string        `json:"error"`
+	Message    string        `json:"error_description"`
 }
 
 func (e WonError) Error() string {
-	return fmt.Sprintf("code:%s,message:%s", e.Code, e.Message)
+	return fmt.Sprintf("status:%d,path:%s,code:%s,message:%s", e.StatusCode, e.Path, e.Code, e.Message)
+}
+
+// Cause returns the sentinel error matching the error code, or nil when the
+// code is unknown.
+func (e WonError) Cause() error {
+	if cause, ok := errorCauses[e.Code]; ok {
+		return cause
+	}
+	if e.StatusCode == http.StatusTooManyRequests {
+		return ErrRateLimited
+	}
+	return nil
+}
+
+// Is reports whether target is the cause of e, so that
+// errors.Is(err, ErrInsufficientFunds) works on errors returned by Service.
+func (e WonError) Is(target error) bool {
+	cause := e.Cause()
+	return cause != nil && cause == target
+}
+
+func IsInsufficientFunds(err error) bool {
+	return errors.Is(err, ErrInsufficientFunds)
+}
+
+func IsInvalidSignature(err error) bool {
+	return errors.Is(err, ErrInvalidSignature)
+}
+
+func IsTimestampOutOfWindow(err error) bool {
+	return errors.Is(err, ErrTimestampOutOfWindow)
+}
+
+func IsRateLimited(err error) bool {
+	return errors.Is(err, ErrRateLimited)
+}
+
+func IsOrderNotFound(err error) bool {
+	return errors.Is(err, ErrOrderNotFound)
+}
+
+func IsUnknownMarket(err error) bool {
+	return errors.Is(err, ErrUnknownMarket)
 }
@@ -0,0 +1,219 @@
+package pkg
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+)
+
+// ExportFormat is a file format written by ExportTrades and ExportOrders.
+type ExportFormat string
+
+const (
+	// FormatCSV writes a header line, then one comma separated line per
+	// record.
+	FormatCSV ExportFormat = "csv"
+	// FormatJSONL writes one JSON object per line, keyed by column name.
+	FormatJSONL ExportFormat = "jsonl"
+	// FormatColumnar writes the compact columnar format read by
+	// ReadColumnar.
+	FormatColumnar ExportFormat = "columnar"
+)
+
+// The column schemas of exported trades and orders. Columns are only ever
+// appended, so files written by older versions stay readable.
+var (
+	TradeColumns = []string{"id", "order_id", "market", "side", "price", "qty", "time"}
+	OrderColumns = []string{"id", "client_order_id", "market", "side", "ord_type", "state", "price", "volume",
+		"remaining_volume", "executed_rate", "funds", "stop_price", "created_at_stamp"}
+)
+
+// RecordWriter writes records of a fixed column schema. Records may be
+// buffered until Flush.
+type RecordWriter interface {
+	Write(record []string) error
+	Flush() error
+}
+
+// NewRecordWriter returns a writer of format on w. When appending to a file
+// written before, the header is left out. Columnar appends check the header
+// of the file against columns, so w must then be readable and seekable, as
+// an *os.File opened with os.O_RDWR is.
+func NewRecordWriter(format ExportFormat, w io.Writer, columns []string, appending bool) (RecordWriter, error) {
+	switch format {
+	case FormatCSV:
+		cw := &csvWriter{w: csv.NewWriter(w)}
+		if !appending {
+			if err := cw.w.Write(columns); err != nil {
+				return nil, err
+			}
+		}
+		return cw, nil
+	case FormatJSONL:
+		return &jsonlWriter{enc: json.NewEncoder(w), columns: columns}, nil
+	case FormatColumnar:
+		return newColumnarWriter(w, columns, appending)
+	}
+	return nil, errors.New(fmt.Sprintf("unknown export format:%s", format))
+}
+
+type csvWriter struct {
+	w *csv.Writer
+}
+
+func (cw *csvWriter) Write(record []string) error {
+	return cw.w.Write(record)
+}
+
+func (cw *csvWriter) Flush() error {
+	cw.w.Flush()
+	return cw.w.Error()
+}
+
+type jsonlWriter struct {
+	enc     *json.Encoder
+	columns []string
+}
+
+func (jw *jsonlWriter) Write(record []string) error {
+	row := make(map[string]string, len(jw.columns))
+	for i, c := range jw.columns {
+		row[c] = record[i]
+	}
+	return jw.enc.Encode(row)
+}
+
+func (jw *jsonlWriter) Flush() error {
+	return nil
+}
+
+// ExportCheckpoint records how far an export got, so that the next run
+// resumes after it. LastStamp is only used by order exports.
+type ExportCheckpoint struct {
+	LastId    int64 `json:"last_id"`
+	LastStamp int64 `json:"last_stamp"`
+}
+
+// LoadCheckpoint reads the checkpoint saved at path. A missing file is an
+// empty checkpoint.
+func LoadCheckpoint(path string) (*ExportCheckpoint, error) {
+	cp := &ExportCheckpoint{}
+	data, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return cp, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, cp); err != nil {
+		return nil, errors.New(fmt.Sprintf("checkpoint %s unmarshal failed:%s", path, err.Error()))
+	}
+	return cp, nil
+}
+
+// Save writes the checkpoint to path, replacing the previous one atomically.
+func (cp *ExportCheckpoint) Save(path string) error {
+	data, err := json.Marshal(cp)
+	if err != nil {
+		return err
+	}
+	tmp, err := ioutil.TempFile(filepath.Dir(path), filepath.Base(path)+".tmp")
+	if err != nil {
+		return err
+	}
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// ExportTrades writes the trades of market after cp.LastId to w, oldest
+// first, and advances cp to the last trade written. w is flushed before
+// returning, also on error, so cp always matches what was written. It
+// returns the number of trades written.
+func ExportTrades(ctx context.Context, service Service, market string, w RecordWriter, cp *ExportCheckpoint) (int, error) {
+	it := NewMyTradesIterator(ctx, service, TradeRequest{Market: market, FromId: cp.LastId + 1})
+	n := 0
+	for it.Next() {
+		if err := w.Write(tradeRecord(market, it.Trade())); err != nil {
+			return n, err
+		}
+		cp.LastId = it.Trade().Id
+		n++
+	}
+	return n, flushAfter(w, it.Err())
+}
+
+// ExportOrders writes the orders of market created from cp.LastStamp on, and
+// after cp.LastId, to w, oldest first. It behaves like ExportTrades, but
+// stops at the oldest order that is still open, so that every order is
+// exported once, in its final state; the next run resumes from it.
+func ExportOrders(ctx context.Context, service Service, market string, w RecordWriter, cp *ExportCheckpoint) (int, error) {
+	it := NewOrdersIterator(ctx, service, OrdersRequest{Market: market, StartAtStamp: cp.LastStamp})
+	n := 0
+	for it.Next() {
+		o := it.Order()
+		if o.CreatedAtStamp == cp.LastStamp && o.Id <= cp.LastId {
+			continue
+		}
+		if !o.State.IsTerminal() {
+			break
+		}
+		if err := w.Write(orderRecord(o)); err != nil {
+			return n, err
+		}
+		cp.LastId, cp.LastStamp = o.Id, o.CreatedAtStamp
+		n++
+	}
+	return n, flushAfter(w, it.Err())
+}
+
+func flushAfter(w RecordWriter, err error) error {
+	if flushErr := w.Flush(); err == nil {
+		err = flushErr
+	}
+	return err
+}
+
+func tradeRecord(market string, t *MyTrade) []string {
+	return []string{
+		strconv.FormatInt(t.Id, 10),
+		strconv.FormatInt(t.OrderId, 10),
+		market,
+		string(t.Side),
+		t.Price.String(),
+		t.Quantity.String(),
+		strconv.FormatInt(t.CreateAt, 10),
+	}
+}
+
+func orderRecord(o *Order) []string {
+	return []string{
+		strconv.FormatInt(o.Id, 10),
+		o.ClientOrderId,
+		o.Market,
+		string(o.Side),
+		string(o.OrdType),
+		string(o.State),
+		o.Price.String(),
+		o.Volume.String(),
+		o.RemainingVolume.String(),
+		o.ExecutedRate.String(),
+		o.Funds.String(),
+		o.StopPrice.String(),
+		strconv.FormatInt(o.CreatedAtStamp, 10),
+	}
+}
@@ -0,0 +1,187 @@
+package pkg
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+// DefaultPageSize is the page size of the iterators when the request sets no
+// Limit.
+const DefaultPageSize = 100
+
+// OrdersIterator walks the orders matching an OrdersRequest page by page,
+// oldest first, from StartAtStamp up to EndAtStamp. Use it as
+//
+//	it := NewOrdersIterator(ctx, service, OrdersRequest{Market: "wonbtc"})
+//	for it.Next() {
+//		order := it.Order()
+//	}
+//	if err := it.Err(); err != nil {
+//	}
+//
+// Pages are requested through the service, so they wait for its rate
+// limiter. Iteration stops early when ctx is done.
+type OrdersIterator struct {
+	ctx     context.Context
+	service Service
+	req     OrdersRequest
+
+	page []*Order
+	pos  int
+	cur  *Order
+	// boundary holds the ids already returned with the created_at_stamp the
+	// next page starts from, since that page returns them again.
+	boundary map[int64]bool
+	last     bool
+	err      error
+}
+
+func NewOrdersIterator(ctx context.Context, service Service, req OrdersRequest) *OrdersIterator {
+	if req.Limit <= 0 {
+		req.Limit = DefaultPageSize
+	}
+	return &OrdersIterator{ctx: ctx, service: service, req: req, boundary: make(map[int64]bool)}
+}
+
+// Next advances to the next order. It returns false at the end of the range
+// or on error.
+func (it *OrdersIterator) Next() bool {
+	for {
+		if it.err != nil {
+			return false
+		}
+		if err := it.ctx.Err(); err != nil {
+			it.err = err
+			return false
+		}
+		for it.pos < len(it.page) {
+			o := it.page[it.pos]
+			it.pos++
+			if o.CreatedAtStamp == it.req.StartAtStamp && it.boundary[o.Id] {
+				continue
+			}
+			if o.CreatedAtStamp != it.req.StartAtStamp {
+				it.req.StartAtStamp = o.CreatedAtStamp
+				it.boundary = make(map[int64]bool)
+			}
+			it.boundary[o.Id] = true
+			it.cur = o
+			return true
+		}
+		if it.last {
+			return false
+		}
+		it.fetch()
+	}
+}
+
+// fetch requests the page starting at the stamp of the last order returned.
+func (it *OrdersIterator) fetch() {
+	page, err := it.service.GetOrdersCtx(it.ctx, it.req)
+	if err != nil {
+		it.err = err
+		return
+	}
+	it.page, it.pos = page, 0
+	it.last = len(page) < it.req.Limit
+	if !it.last && allSeen(page, it.req.StartAtStamp, it.boundary) {
+		it.err = errors.New(fmt.Sprintf("more than %d orders created at %d, raise the page limit", it.req.Limit, it.req.StartAtStamp))
+	}
+}
+
+func allSeen(page []*Order, stamp int64, seen map[int64]bool) bool {
+	for _, o := range page {
+		if o.CreatedAtStamp != stamp || !seen[o.Id] {
+			return false
+		}
+	}
+	return true
+}
+
+// Order returns the current order.
+func (it *OrdersIterator) Order() *Order {
+	return it.cur
+}
+
+// Err returns the error that stopped the iteration, if any.
+func (it *OrdersIterator) Err() error {
+	return it.err
+}
+
+// MyTradesIterator walks the trades of a market from FromId on, page by
+// page, in increasing id order. It is used like OrdersIterator.
+type MyTradesIterator struct {
+	ctx     context.Context
+	service Service
+	req     TradeRequest
+
+	page []*MyTrade
+	pos  int
+	cur  *MyTrade
+	// lastId is the id of the last trade returned; pages may repeat it.
+	lastId int64
+	last   bool
+	err    error
+}
+
+func NewMyTradesIterator(ctx context.Context, service Service, req TradeRequest) *MyTradesIterator {
+	if req.Limit <= 0 {
+		req.Limit = DefaultPageSize
+	}
+	if req.FromId <= 0 {
+		// Without from_id the exchange returns the latest trades.
+		req.FromId = 1
+	}
+	return &MyTradesIterator{ctx: ctx, service: service, req: req, lastId: req.FromId - 1}
+}
+
+// Next advances to the next trade. It returns false at the end of the
+// history or on error.
+func (it *MyTradesIterator) Next() bool {
+	for {
+		if it.err != nil {
+			return false
+		}
+		if err := it.ctx.Err(); err != nil {
+			it.err = err
+			return false
+		}
+		for it.pos < len(it.page) {
+			t := it.page[it.pos]
+			it.pos++
+			if t.Id <= it.lastId {
+				continue
+			}
+			it.lastId = t.Id
+			it.cur = t
+			return true
+		}
+		if it.last {
+			return false
+		}
+		it.fetch()
+	}
+}
+
+func (it *MyTradesIterator) fetch() {
+	req := it.req
+	req.FromId = it.lastId + 1
+	page, err := it.service.MyTradesCtx(it.ctx, req)
+	if err != nil {
+		it.err = err
+		return
+	}
+	it.page, it.pos = page, 0
+	it.last = len(page) < req.Limit
+}
+
+// Trade returns the current trade.
+func (it *MyTradesIterator) Trade() *MyTrade {
+	return it.cur
+}
+
+// Err returns the error that stopped the iteration, if any.
+func (it *MyTradesIterator) Err() error {
+	return it.err
+}
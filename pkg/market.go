@@ -0,0 +1,135 @@
+package pkg
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// DefaultMarketCacheTTL is how long ExchangeInfo answers from its cache
+// before fetching the markets again.
+const DefaultMarketCacheTTL = time.Hour
+
+// MarketTrading is the status of a market accepting orders.
+const MarketTrading = "trading"
+
+// Trading reports whether the market accepts orders.
+func (m MarketInfo) Trading() bool {
+	return m.Status == MarketTrading
+}
+
+// WithMarketCacheTTL sets how long the markets fetched by ExchangeInfo are
+// cached. A negative ttl disables the cache.
+func WithMarketCacheTTL(ttl time.Duration) Option {
+	return func(ws *wonService) {
+		ws.markets.ttl = ttl
+	}
+}
+
+// marketCache keeps the last ExchangeInfo response.
+type marketCache struct {
+	mu      sync.Mutex
+	ttl     time.Duration
+	info    *ExchangeInfo
+	byName  map[string]MarketInfo
+	fetched time.Time
+	// fetching is the request in flight, if any.
+	fetching *marketFetch
+}
+
+// marketFetch is an exchange_info request shared by the callers that arrive
+// while it runs. done is closed once info and err are set.
+type marketFetch struct {
+	done chan struct{}
+	info *ExchangeInfo
+	err  error
+}
+
+func (ws *wonService) ExchangeInfo() (*ExchangeInfo, error) {
+	return ws.ExchangeInfoCtx(ws.Ctx)
+}
+func (ws *wonService) MarketInfo(market string) (*MarketInfo, error) {
+	return ws.MarketInfoCtx(ws.Ctx, market)
+}
+
+// copy returns a copy of info that callers may change without touching the
+// cache.
+func (info *ExchangeInfo) copy() *ExchangeInfo {
+	c := *info
+	c.Markets = append([]MarketInfo(nil), info.Markets...)
+	return &c
+}
+
+// ExchangeInfoCtx returns a copy of the trading rules of every market, from
+// the cache while it is fresh. Concurrent callers share a single request,
+// each waiting on it only until its own ctx is done.
+func (ws *wonService) ExchangeInfoCtx(ctx context.Context) (*ExchangeInfo, error) {
+	c := &ws.markets
+	for {
+		c.mu.Lock()
+		if c.info != nil && c.ttl >= 0 && ws.clock().Sub(c.fetched) < c.ttl {
+			info := c.info
+			c.mu.Unlock()
+			return info.copy(), nil
+		}
+		if f := c.fetching; f != nil {
+			c.mu.Unlock()
+			select {
+			case <-ctx.Done():
+				return nil, ctx.Err()
+			case <-f.done:
+			}
+			if f.err == nil {
+				return f.info.copy(), nil
+			}
+			// The request may have failed on its caller's ctx; try again
+			// with ours.
+			continue
+		}
+		f := &marketFetch{done: make(chan struct{})}
+		c.fetching = f
+		c.mu.Unlock()
+
+		f.info, f.err = ws.fetchExchangeInfo(ctx)
+		c.mu.Lock()
+		if f.err == nil {
+			c.info = f.info
+			c.byName = make(map[string]MarketInfo, len(f.info.Markets))
+			for _, m := range f.info.Markets {
+				c.byName[m.Market] = m
+			}
+			c.fetched = ws.clock()
+		}
+		c.fetching = nil
+		c.mu.Unlock()
+		close(f.done)
+		if f.err != nil {
+			return nil, f.err
+		}
+		return f.info.copy(), nil
+	}
+}
+
+func (ws *wonService) fetchExchangeInfo(ctx context.Context) (*ExchangeInfo, error) {
+	params := make(map[string]string)
+	var info ExchangeInfo
+	if err := ws.call(ctx, "GET", "api/v1/exchange_info", params, false, false, &info); err != nil {
+		return nil, err
+	}
+	return &info, nil
+}
+
+// MarketInfoCtx returns the trading rules of market. It fails with
+// ErrUnknownMarket when the exchange does not list it.
+func (ws *wonService) MarketInfoCtx(ctx context.Context, market string) (*MarketInfo, error) {
+	if _, err := ws.ExchangeInfoCtx(ctx); err != nil {
+		return nil, err
+	}
+	ws.markets.mu.Lock()
+	m, ok := ws.markets.byName[market]
+	ws.markets.mu.Unlock()
+	if !ok {
+		return nil, ErrUnknownMarket
+	}
+	return &m, nil
+}
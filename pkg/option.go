@@ -0,0 +1,160 @@
+package pkg
+
+import (
+	"context"
+	"crypto/tls"
+	"net"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/go-kit/kit/log"
+)
+
+// Option configures a Service created by NewService.
+type Option func(*wonService)
+
+// WithAPIKey sets the key sent in the X-Won-Apikey header.
+func WithAPIKey(apiKey string) Option {
+	return func(ws *wonService) {
+		ws.APIKey = apiKey
+	}
+}
+
+// WithSigner sets the signer used for signed endpoints.
+func WithSigner(signer Signer) Option {
+	return func(ws *wonService) {
+		ws.Signer = signer
+	}
+}
+
+// WithLogger sets the logger. A nil logger discards everything.
+func WithLogger(logger log.Logger) Option {
+	return func(ws *wonService) {
+		ws.Logger = logger
+	}
+}
+
+// WithContext sets the context used by the methods that do not take one. It
+// also bounds the time sync loop started by WithTimeSync.
+func WithContext(ctx context.Context) Option {
+	return func(ws *wonService) {
+		ws.Ctx = ctx
+	}
+}
+
+// WithUserAgent sets the User-Agent header of every request.
+func WithUserAgent(userAgent string) Option {
+	return func(ws *wonService) {
+		ws.UserAgent = userAgent
+	}
+}
+
+// WithRecvWindow sets the recv_window, in milliseconds, sent with signed
+// requests that leave RecvWindow zero. It defaults to DefaultRecvWindow; a
+// negative value omits recv_window and lets the exchange apply its own.
+func WithRecvWindow(recvWindow int) Option {
+	return func(ws *wonService) {
+		ws.RecvWindow = recvWindow
+	}
+}
+
+// WithClock sets the clock used to stamp signed requests that leave
+// Timestamp zero. It defaults to time.Now.
+func WithClock(now func() time.Time) Option {
+	return func(ws *wonService) {
+		ws.clock = now
+	}
+}
+
+type httpConfig struct {
+	timeout         time.Duration
+	proxy           func(*http.Request) (*url.URL, error)
+	tlsConfig       *tls.Config
+	maxIdleConns    int
+	idleConnTimeout time.Duration
+	keepAlive       time.Duration
+}
+
+func defaultHTTPConfig() httpConfig {
+	return httpConfig{
+		proxy:           http.ProxyFromEnvironment,
+		maxIdleConns:    100,
+		idleConnTimeout: 90 * time.Second,
+		keepAlive:       30 * time.Second,
+	}
+}
+
+// WithHTTPClient makes the service send every request through c. The
+// transport options below are ignored when a client is supplied.
+func WithHTTPClient(c *http.Client) Option {
+	return func(ws *wonService) {
+		ws.Client = c
+	}
+}
+
+// WithTimeout bounds the total time of a single HTTP round trip.
+func WithTimeout(d time.Duration) Option {
+	return func(ws *wonService) {
+		ws.httpConfig.timeout = d
+	}
+}
+
+// WithProxy routes requests through the proxy at proxyURL.
+func WithProxy(proxyURL *url.URL) Option {
+	return func(ws *wonService) {
+		ws.httpConfig.proxy = http.ProxyURL(proxyURL)
+	}
+}
+
+// WithTLSConfig sets the TLS configuration of the pooled transport.
+func WithTLSConfig(c *tls.Config) Option {
+	return func(ws *wonService) {
+		ws.httpConfig.tlsConfig = c
+	}
+}
+
+// WithMaxIdleConns sets how many idle connections to the exchange are kept
+// open for reuse.
+func WithMaxIdleConns(n int) Option {
+	return func(ws *wonService) {
+		ws.httpConfig.maxIdleConns = n
+	}
+}
+
+// WithIdleConnTimeout sets how long an idle connection stays in the pool.
+func WithIdleConnTimeout(d time.Duration) Option {
+	return func(ws *wonService) {
+		ws.httpConfig.idleConnTimeout = d
+	}
+}
+
+// WithKeepAlive sets the TCP keep-alive period of pooled connections. A
+// negative duration disables keep-alives.
+func WithKeepAlive(d time.Duration) Option {
+	return func(ws *wonService) {
+		ws.httpConfig.keepAlive = d
+	}
+}
+
+func newHTTPClient(c httpConfig) *http.Client {
+	dialer := &net.Dialer{
+		Timeout:   30 * time.Second,
+		KeepAlive: c.keepAlive,
+	}
+	transport := &http.Transport{
+		Proxy:                 c.proxy,
+		DialContext:           dialer.DialContext,
+		TLSClientConfig:       c.tlsConfig,
+		MaxIdleConns:          c.maxIdleConns,
+		MaxIdleConnsPerHost:   c.maxIdleConns,
+		IdleConnTimeout:       c.idleConnTimeout,
+		TLSHandshakeTimeout:   10 * time.Second,
+		ExpectContinueTimeout: 1 * time.Second,
+		DisableKeepAlives:     c.keepAlive < 0,
+	}
+	return &http.Client{
+		Transport: transport,
+		Timeout:   c.timeout,
+	}
+}
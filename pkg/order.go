@@ -0,0 +1,170 @@
+package pkg
+
+import "context"
+
+// LimitOrder returns a good-till-cancelled limit order.
+func LimitOrder(market string, side Side, price, volume Decimal) CreateOrderRequest {
+	return CreateOrderRequest{Market: market, Side: side, OrdType: OrdTypeLimit, Price: price, Volume: volume}
+}
+
+// MarketOrder returns a market order for a base volume.
+func MarketOrder(market string, side Side, volume Decimal) CreateOrderRequest {
+	return CreateOrderRequest{Market: market, Side: side, OrdType: OrdTypeMarket, Volume: volume}
+}
+
+// MarketOrderByFunds returns a market order spending, or for a sell
+// receiving, funds of the quote currency.
+func MarketOrderByFunds(market string, side Side, funds Decimal) CreateOrderRequest {
+	return CreateOrderRequest{Market: market, Side: side, OrdType: OrdTypeMarket, Funds: funds}
+}
+
+// StopLossOrder returns an order that becomes a market order once the last
+// price crosses stopPrice against the position.
+func StopLossOrder(market string, side Side, stopPrice, volume Decimal) CreateOrderRequest {
+	return CreateOrderRequest{Market: market, Side: side, OrdType: OrdTypeStopLoss, StopPrice: stopPrice, Volume: volume}
+}
+
+// StopLossLimitOrder is StopLossOrder entering the book as a limit order at
+// price.
+func StopLossLimitOrder(market string, side Side, stopPrice, price, volume Decimal) CreateOrderRequest {
+	return CreateOrderRequest{Market: market, Side: side, OrdType: OrdTypeStopLossLimit, StopPrice: stopPrice, Price: price, Volume: volume}
+}
+
+// TakeProfitOrder returns an order that becomes a market order once the last
+// price crosses stopPrice in favour of the position.
+func TakeProfitOrder(market string, side Side, stopPrice, volume Decimal) CreateOrderRequest {
+	return CreateOrderRequest{Market: market, Side: side, OrdType: OrdTypeTakeProfit, StopPrice: stopPrice, Volume: volume}
+}
+
+// TakeProfitLimitOrder is TakeProfitOrder entering the book as a limit order
+// at price.
+func TakeProfitLimitOrder(market string, side Side, stopPrice, price, volume Decimal) CreateOrderRequest {
+	return CreateOrderRequest{Market: market, Side: side, OrdType: OrdTypeTakeProfitLimit, StopPrice: stopPrice, Price: price, Volume: volume}
+}
+
+// WithTimeInForce returns a copy of r with its time in force set.
+func (r CreateOrderRequest) WithTimeInForce(tif TimeInForce) CreateOrderRequest {
+	r.TimeInForce = tif
+	return r
+}
+
+// WithPostOnly returns a copy of r that only adds liquidity.
+func (r CreateOrderRequest) WithPostOnly() CreateOrderRequest {
+	r.PostOnly = true
+	return r
+}
+
+// Check reports the first field inconsistent with the order type as a
+// *ValidationError. It needs no market data; CreateOrder always runs it.
+//
+// Limit prices are required exactly by the limit types, stop prices by the
+// stop types, and funds are only accepted by market orders, in place of the
+// volume. Market orders cannot rest, so they take IOC or FOK only, and
+// post-only orders must be GTC limit orders.
+func (r CreateOrderRequest) Check() error {
+	if !r.Side.Valid() {
+		return invalid("side", "unknown side %q", r.Side)
+	}
+	if !r.OrdType.Valid() {
+		return invalid("ord_type", "unknown order type %q", r.OrdType)
+	}
+	if r.TimeInForce != "" && !r.TimeInForce.Valid() {
+		return invalid("time_in_force", "unknown time in force %q", r.TimeInForce)
+	}
+
+	switch {
+	case r.OrdType.HasPrice() && r.Price.Sign() <= 0:
+		return invalid("price", "%s order needs a positive price", r.OrdType)
+	case !r.OrdType.HasPrice() && !r.Price.IsZero():
+		return invalid("price", "%s order takes no price", r.OrdType)
+	case r.OrdType.IsStop() && r.StopPrice.Sign() <= 0:
+		return invalid("stop_price", "%s order needs a positive stop price", r.OrdType)
+	case !r.OrdType.IsStop() && !r.StopPrice.IsZero():
+		return invalid("stop_price", "%s order takes no stop price", r.OrdType)
+	}
+
+	if r.OrdType == OrdTypeMarket && !r.Funds.IsZero() {
+		if !r.Volume.IsZero() {
+			return invalid("funds", "market order takes either volume or funds")
+		}
+		if r.Funds.Sign() < 0 {
+			return invalid("funds", "must be positive")
+		}
+	} else {
+		if !r.Funds.IsZero() {
+			return invalid("funds", "%s order takes no funds", r.OrdType)
+		}
+		if r.Volume.Sign() <= 0 {
+			return invalid("volume", "must be positive")
+		}
+	}
+
+	if r.OrdType == OrdTypeMarket && r.TimeInForce == GTC {
+		return invalid("time_in_force", "market order cannot be GTC")
+	}
+	if r.PostOnly {
+		if !r.OrdType.HasPrice() {
+			return invalid("post_only", "%s order cannot be post-only", r.OrdType)
+		}
+		if r.TimeInForce != "" && r.TimeInForce != GTC {
+			return invalid("post_only", "post-only order cannot be %s", r.TimeInForce)
+		}
+	}
+	return nil
+}
+
+// prepareOrder validates cor, with the configured OrderValidator if any, and
+// gives it a client order id.
+func (ws *wonService) prepareOrder(ctx context.Context, cor CreateOrderRequest) (CreateOrderRequest, error) {
+	prepared, errs := ws.prepareOrders(ctx, []CreateOrderRequest{cor})
+	return prepared[0], errs[0]
+}
+
+// prepareOrders prepares orders as prepareOrder does. An OrderValidator
+// checks them together, so that they must fit the balance as a whole.
+func (ws *wonService) prepareOrders(ctx context.Context, orders []CreateOrderRequest) ([]CreateOrderRequest, []error) {
+	prepared := make([]CreateOrderRequest, len(orders))
+	errs := make([]error, len(orders))
+	if ws.validator != nil {
+		prepared, errs = ws.validator.ValidateAll(ctx, orders)
+	} else {
+		for i, cor := range orders {
+			prepared[i], errs[i] = cor, cor.Check()
+		}
+	}
+	for i := range prepared {
+		if errs[i] == nil && prepared[i].ClientOrderId == "" {
+			prepared[i].ClientOrderId = NewClientOrderId()
+		}
+	}
+	return prepared, errs
+}
+
+// orderParams maps cor onto the parameters of the create endpoint. Unset
+// values are left out.
+func orderParams(cor CreateOrderRequest) map[string]string {
+	params := make(map[string]string)
+	params["market"] = cor.Market
+	params["side"] = string(cor.Side)
+	params["ord_type"] = string(cor.OrdType)
+	if !cor.Volume.IsZero() {
+		params["volume"] = cor.Volume.String()
+	}
+	if !cor.Price.IsZero() {
+		params["price"] = cor.Price.String()
+	}
+	if !cor.Funds.IsZero() {
+		params["funds"] = cor.Funds.String()
+	}
+	if !cor.StopPrice.IsZero() {
+		params["stop_price"] = cor.StopPrice.String()
+	}
+	if cor.TimeInForce != "" {
+		params["time_in_force"] = string(cor.TimeInForce)
+	}
+	if cor.PostOnly {
+		params["post_only"] = "true"
+	}
+	params["client_order_id"] = cor.ClientOrderId
+	return params
+}
@@ -0,0 +1,371 @@
+package pkg
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+)
+
+var (
+	// ErrOrderBookGap is returned by OrderBook.Apply when an update does not
+	// follow the last applied one. The book must be synced again.
+	ErrOrderBookGap = errors.New("order book update out of sequence")
+	// ErrOrderBookNotSynced is returned by queries on a book that has no
+	// snapshot yet.
+	ErrOrderBookNotSynced = errors.New("order book not synced")
+	// ErrInsufficientDepth is returned by VWAP when the book does not hold
+	// the requested size.
+	ErrInsufficientDepth = errors.New("insufficient order book depth")
+)
+
+// BookSide selects the bids or the asks of an OrderBook.
+type BookSide int
+
+const (
+	Bids BookSide = iota
+	Asks
+)
+
+// OrderBook is a local copy of the order book of a market, bootstrapped from
+// a Depth snapshot and kept current by applying DepthUpdates in sequence.
+// It is safe for concurrent use.
+type OrderBook struct {
+	Market string
+	// Resync paces the snapshots Follow fetches while they lag behind the
+	// buffered updates, and caps how many it fetches before giving up with
+	// ErrOrderBookGap.
+	Resync RetryPolicy
+
+	service Service
+	limit   int
+
+	mu           sync.RWMutex
+	synced       bool
+	lastUpdateId int64
+	// bids are sorted by descending price, asks by ascending price.
+	bids []PriceLevel
+	asks []PriceLevel
+	// pending buffers the updates received while the book is not synced,
+	// up to maxPendingUpdates.
+	pending []DepthUpdate
+}
+
+// maxPendingUpdates bounds the updates buffered while the book is out of
+// sync. The oldest are dropped first; a snapshot recent enough for the
+// remaining ones covers them anyway.
+const maxPendingUpdates = 1000
+
+// DefaultResyncPolicy returns the Resync policy of new order books.
+func DefaultResyncPolicy() RetryPolicy {
+	return RetryPolicy{
+		MaxAttempts: 5,
+		BaseDelay:   200 * time.Millisecond,
+		MaxDelay:    5 * time.Second,
+		Jitter:      0.2,
+	}
+}
+
+// NewOrderBook returns an empty book for market, synced from snapshots of up
+// to limit levels fetched through service.
+func NewOrderBook(market string, service Service, limit int) *OrderBook {
+	return &OrderBook{
+		Market:  market,
+		Resync:  DefaultResyncPolicy(),
+		service: service,
+		limit:   limit,
+	}
+}
+
+// Sync fetches a fresh snapshot, replaces the book with it and replays the
+// buffered updates that follow it. It returns ErrOrderBookGap when the
+// snapshot is older than the buffered updates.
+func (b *OrderBook) Sync(ctx context.Context) error {
+	snapshot, err := b.service.DepthCtx(ctx, DepthRequest{Market: b.Market, Limit: b.limit})
+	if err != nil {
+		return err
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if err := b.reset(snapshot); err != nil {
+		return err
+	}
+	pending := b.pending
+	b.pending = nil
+	for i, u := range pending {
+		if err := b.apply(u); err != nil {
+			if err == ErrOrderBookGap {
+				b.pending = append(b.pending, pending[i+1:]...)
+			}
+			return err
+		}
+	}
+	return nil
+}
+
+// Reset replaces the book with snapshot.
+func (b *OrderBook) Reset(snapshot *DepthResult) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.reset(snapshot)
+}
+
+func (b *OrderBook) reset(snapshot *DepthResult) error {
+	b.bids, b.asks = nil, nil
+	b.update(Bids, snapshot.Bids)
+	b.update(Asks, snapshot.Asks)
+	b.lastUpdateId = snapshot.LastUpdateId
+	b.synced = true
+	return nil
+}
+
+// Apply applies u to the book. Updates already contained in the book are
+// ignored and updates received before the first Sync are buffered. When u
+// does not follow the last applied update the book is marked out of sync,
+// u is buffered and ErrOrderBookGap is returned; call Sync to recover.
+func (b *OrderBook) Apply(u DepthUpdate) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if !b.synced {
+		b.buffer(u)
+		return nil
+	}
+	return b.apply(u)
+}
+
+func (b *OrderBook) apply(u DepthUpdate) error {
+	if u.LastUpdateId <= b.lastUpdateId {
+		return nil
+	}
+	if u.FirstUpdateId > b.lastUpdateId+1 {
+		b.synced = false
+		b.buffer(u)
+		return ErrOrderBookGap
+	}
+	b.update(Bids, u.Bids)
+	b.update(Asks, u.Asks)
+	b.lastUpdateId = u.LastUpdateId
+	return nil
+}
+
+func (b *OrderBook) buffer(u DepthUpdate) {
+	b.pending = append(b.pending, u)
+	if n := len(b.pending) - maxPendingUpdates; n > 0 {
+		b.pending = append(b.pending[:0], b.pending[n:]...)
+	}
+}
+
+// Follow syncs the book and applies updates until ctx is done or updates is
+// closed, syncing again whenever a gap is detected. It returns
+// ErrOrderBookGap when no snapshot catches up within b.Resync.
+func (b *OrderBook) Follow(ctx context.Context, updates <-chan DepthUpdate) error {
+	if err := b.resync(ctx); err != nil {
+		return err
+	}
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case u, ok := <-updates:
+			if !ok {
+				return nil
+			}
+			if err := b.Apply(u); err == ErrOrderBookGap {
+				if err := b.resync(ctx); err != nil {
+					return err
+				}
+			} else if err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// resync syncs until a snapshot recent enough for the buffered updates is
+// fetched, waiting between attempts as set by b.Resync.
+func (b *OrderBook) resync(ctx context.Context) error {
+	attempts := b.Resync.MaxAttempts
+	if attempts < 1 {
+		attempts = 1
+	}
+	for attempt := 0; ; attempt++ {
+		err := b.Sync(ctx)
+		if err != ErrOrderBookGap || attempt+1 >= attempts {
+			return err
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(b.Resync.backoff(attempt, 0)):
+		}
+	}
+}
+
+// LastUpdateId returns the id of the last update applied to the book.
+func (b *OrderBook) LastUpdateId() int64 {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	return b.lastUpdateId
+}
+
+// Synced reports whether the book holds a consistent snapshot.
+func (b *OrderBook) Synced() bool {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	return b.synced
+}
+
+// update sets the amount of every level in levels on side, removing the
+// levels whose amount is zero.
+func (b *OrderBook) update(side BookSide, levels []PriceLevel) {
+	book := &b.bids
+	if side == Asks {
+		book = &b.asks
+	}
+	for _, l := range levels {
+		i := sort.Search(len(*book), func(i int) bool {
+			c := (*book)[i].Price.Cmp(l.Price)
+			if side == Bids {
+				return c <= 0
+			}
+			return c >= 0
+		})
+		found := i < len(*book) && (*book)[i].Price.Cmp(l.Price) == 0
+		switch {
+		case l.Amount.IsZero() && found:
+			*book = append((*book)[:i], (*book)[i+1:]...)
+		case l.Amount.IsZero():
+		case found:
+			(*book)[i] = l
+		default:
+			*book = append(*book, PriceLevel{})
+			copy((*book)[i+1:], (*book)[i:])
+			(*book)[i] = l
+		}
+	}
+}
+
+func (b *OrderBook) side(side BookSide) []PriceLevel {
+	if side == Asks {
+		return b.asks
+	}
+	return b.bids
+}
+
+// Levels returns up to depth levels of side, best first. A depth of zero
+// returns every level.
+func (b *OrderBook) Levels(side BookSide, depth int) []PriceLevel {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	book := b.side(side)
+	if depth <= 0 || depth > len(book) {
+		depth = len(book)
+	}
+	levels := make([]PriceLevel, depth)
+	copy(levels, book)
+	return levels
+}
+
+// BestBid returns the highest bid, or false when there is none.
+func (b *OrderBook) BestBid() (PriceLevel, bool) {
+	return b.best(Bids)
+}
+
+// BestAsk returns the lowest ask, or false when there is none.
+func (b *OrderBook) BestAsk() (PriceLevel, bool) {
+	return b.best(Asks)
+}
+
+func (b *OrderBook) best(side BookSide) (PriceLevel, bool) {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	book := b.side(side)
+	if len(book) == 0 {
+		return PriceLevel{}, false
+	}
+	return book[0], true
+}
+
+func (b *OrderBook) top() (bid, ask Decimal, err error) {
+	if !b.synced {
+		return Decimal{}, Decimal{}, ErrOrderBookNotSynced
+	}
+	if len(b.bids) == 0 || len(b.asks) == 0 {
+		return Decimal{}, Decimal{}, ErrInsufficientDepth
+	}
+	return b.bids[0].Price, b.asks[0].Price, nil
+}
+
+// Spread returns the best ask minus the best bid.
+func (b *OrderBook) Spread() (Decimal, error) {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	bid, ask, err := b.top()
+	if err != nil {
+		return Decimal{}, err
+	}
+	return ask.Sub(bid), nil
+}
+
+// MidPrice returns the average of the best bid and the best ask.
+func (b *OrderBook) MidPrice() (Decimal, error) {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	bid, ask, err := b.top()
+	if err != nil {
+		return Decimal{}, err
+	}
+	sum := bid.Add(ask)
+	return sum.Div(NewDecimalFromInt(2), sum.Scale()+1, RoundHalfEven).Canonical(), nil
+}
+
+// CumulativeVolume returns the amount resting on side at price or better:
+// bids at or above price, asks at or below it.
+func (b *OrderBook) CumulativeVolume(side BookSide, price Decimal) (Decimal, error) {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	if !b.synced {
+		return Decimal{}, ErrOrderBookNotSynced
+	}
+	var total Decimal
+	for _, l := range b.side(side) {
+		c := l.Price.Cmp(price)
+		if (side == Bids && c < 0) || (side == Asks && c > 0) {
+			break
+		}
+		total = total.Add(l.Amount)
+	}
+	return total, nil
+}
+
+// vwapScale is the number of fractional digits VWAP rounds to.
+const vwapScale = 18
+
+// VWAP returns the volume weighted average price of filling size against
+// side: selling into the bids or buying from the asks.
+func (b *OrderBook) VWAP(side BookSide, size Decimal) (Decimal, error) {
+	if size.Sign() <= 0 {
+		return Decimal{}, errors.New(fmt.Sprintf("invalid size:%s", size))
+	}
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	if !b.synced {
+		return Decimal{}, ErrOrderBookNotSynced
+	}
+	var cost Decimal
+	remaining := size
+	for _, l := range b.side(side) {
+		take := l.Amount
+		if take.Cmp(remaining) > 0 {
+			take = remaining
+		}
+		cost = cost.Add(take.Mul(l.Price))
+		remaining = remaining.Sub(take)
+		if remaining.IsZero() {
+			return cost.Div(size, vwapScale, RoundHalfEven).Canonical(), nil
+		}
+	}
+	return Decimal{}, ErrInsufficientDepth
+}
@@ -0,0 +1,194 @@
+package pkg
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"sync"
+	"time"
+)
+
+// Rate limit buckets. Public market data endpoints draw from PublicBucket,
+// signed account and order endpoints from PrivateBucket.
+const (
+	PublicBucket  = "public"
+	PrivateBucket = "private"
+)
+
+// RateLimitPolicy decides what a call does when its bucket is empty.
+type RateLimitPolicy int
+
+const (
+	// RateLimitWait blocks the call until enough tokens are available or its
+	// context is done.
+	RateLimitWait RateLimitPolicy = iota
+	// RateLimitFailFast returns a *RateLimitError immediately.
+	RateLimitFailFast
+)
+
+// RateLimitError is returned by calls rejected by the client-side limiter
+// under RateLimitFailFast. It matches ErrRateLimited.
+type RateLimitError struct {
+	Bucket string
+	// Wait is how long until the call would have been allowed.
+	Wait time.Duration
+}
+
+func (e *RateLimitError) Error() string {
+	return fmt.Sprintf("rate limit of %s bucket exhausted, retry in %s", e.Bucket, e.Wait)
+}
+
+func (e *RateLimitError) Is(target error) bool {
+	return target == ErrRateLimited
+}
+
+// Budget describes the state of a rate limit bucket.
+type Budget struct {
+	Bucket string
+	// Tokens currently available; each call costs its endpoint weight.
+	Tokens float64
+	// Rate is the refill rate in tokens per second.
+	Rate  float64
+	Burst int
+}
+
+// TokenBucket is a token bucket refilled continuously at Rate tokens per
+// second up to Burst tokens. It is safe for concurrent use.
+type TokenBucket struct {
+	mu     sync.Mutex
+	rate   float64
+	burst  float64
+	tokens float64
+	last   time.Time
+	now    func() time.Time
+}
+
+func NewTokenBucket(rate float64, burst int) *TokenBucket {
+	return &TokenBucket{
+		rate:   rate,
+		burst:  float64(burst),
+		tokens: float64(burst),
+		now:    time.Now,
+	}
+}
+
+func (b *TokenBucket) refill() {
+	now := b.now()
+	if !b.last.IsZero() {
+		b.tokens = math.Min(b.burst, b.tokens+now.Sub(b.last).Seconds()*b.rate)
+	}
+	b.last = now
+}
+
+// reserve takes n tokens if available. Otherwise it returns how long until
+// they will be.
+func (b *TokenBucket) reserve(n int) time.Duration {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.refill()
+	need := math.Min(float64(n), b.burst)
+	if b.tokens >= need {
+		b.tokens -= need
+		return 0
+	}
+	if b.rate <= 0 {
+		return time.Duration(math.MaxInt64)
+	}
+	return time.Duration((need - b.tokens) / b.rate * float64(time.Second))
+}
+
+// Allow takes n tokens and reports whether they were available.
+func (b *TokenBucket) Allow(n int) bool {
+	return b.reserve(n) == 0
+}
+
+// Wait blocks until n tokens have been taken or ctx is done.
+func (b *TokenBucket) Wait(ctx context.Context, n int) error {
+	for {
+		wait := b.reserve(n)
+		if wait == 0 {
+			return nil
+		}
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		case <-timer.C:
+		}
+	}
+}
+
+// Tokens returns the number of tokens currently available.
+func (b *TokenBucket) Tokens() float64 {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.refill()
+	return b.tokens
+}
+
+// WithRateLimit limits the calls drawing from bucket to rate weighted calls
+// per second, with bursts of up to burst. Buckets without a limit are not
+// throttled.
+func WithRateLimit(bucket string, rate float64, burst int) Option {
+	return func(ws *wonService) {
+		if ws.buckets == nil {
+			ws.buckets = make(map[string]*TokenBucket)
+		}
+		ws.buckets[bucket] = NewTokenBucket(rate, burst)
+	}
+}
+
+// WithEndpointWeight sets how many tokens a call to endpoint, such as
+// "api/v1/orders", costs. Endpoints cost 1 token by default.
+func WithEndpointWeight(endpoint string, weight int) Option {
+	return func(ws *wonService) {
+		if ws.weights == nil {
+			ws.weights = make(map[string]int)
+		}
+		ws.weights[endpoint] = weight
+	}
+}
+
+// WithRateLimitPolicy sets what calls do when their bucket is empty. The
+// default is RateLimitWait.
+func WithRateLimitPolicy(p RateLimitPolicy) Option {
+	return func(ws *wonService) {
+		ws.rateLimitPolicy = p
+	}
+}
+
+func bucketOf(sign bool) string {
+	if sign {
+		return PrivateBucket
+	}
+	return PublicBucket
+}
+
+// limit takes the tokens needed by a call to endpoint from its bucket.
+func (ws *wonService) limit(ctx context.Context, endpoint string, sign bool) error {
+	bucket := bucketOf(sign)
+	tb, ok := ws.buckets[bucket]
+	if !ok {
+		return nil
+	}
+	weight, ok := ws.weights[endpoint]
+	if !ok {
+		weight = 1
+	}
+	if ws.rateLimitPolicy == RateLimitFailFast {
+		if wait := tb.reserve(weight); wait > 0 {
+			return &RateLimitError{Bucket: bucket, Wait: wait}
+		}
+		return nil
+	}
+	return tb.Wait(ctx, weight)
+}
+
+func (ws *wonService) RateLimitBudget(bucket string) (Budget, bool) {
+	tb, ok := ws.buckets[bucket]
+	if !ok {
+		return Budget{}, false
+	}
+	return Budget{Bucket: bucket, Tokens: tb.Tokens(), Rate: tb.rate, Burst: int(tb.burst)}, true
+}
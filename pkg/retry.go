@@ -0,0 +1,114 @@
+package pkg
+
+import (
+	"context"
+	"errors"
+	"math/rand"
+	"net/http"
+	"net/url"
+	"strconv"
+	"syscall"
+	"time"
+)
+
+// RetryPolicy controls how calls failing with a network error, a 5xx or a
+// 429 response are retried. GET endpoints are retried automatically;
+// CancelOrder only when RetryCancel is set. Every attempt is signed again
+// with a fresh timestamp.
+type RetryPolicy struct {
+	// MaxAttempts is the total number of attempts, the first one included.
+	// Values below 2 disable retries.
+	MaxAttempts int
+	// BaseDelay is the delay before the first retry; it doubles on every
+	// further retry up to MaxDelay.
+	BaseDelay time.Duration
+	MaxDelay  time.Duration
+	// Jitter is the fraction, between 0 and 1, of each delay that is
+	// randomised.
+	Jitter float64
+	// RetryCancel opts CancelOrder into retries.
+	RetryCancel bool
+}
+
+func DefaultRetryPolicy() RetryPolicy {
+	return RetryPolicy{
+		MaxAttempts: 3,
+		BaseDelay:   200 * time.Millisecond,
+		MaxDelay:    5 * time.Second,
+		Jitter:      0.2,
+	}
+}
+
+// WithRetryPolicy replaces the default retry policy. Pass RetryPolicy{} to
+// disable retries.
+func WithRetryPolicy(p RetryPolicy) Option {
+	return func(ws *wonService) {
+		ws.Retry = p
+	}
+}
+
+// backoff returns the delay before retry number attempt+1. A Retry-After
+// sent by the exchange takes precedence over the computed delay.
+func (p RetryPolicy) backoff(attempt int, retryAfter time.Duration) time.Duration {
+	if retryAfter > 0 {
+		return retryAfter
+	}
+	delay := p.BaseDelay
+	for i := 0; i < attempt && (p.MaxDelay <= 0 || delay < p.MaxDelay); i++ {
+		delay *= 2
+	}
+	if p.MaxDelay > 0 && delay > p.MaxDelay {
+		delay = p.MaxDelay
+	}
+	if p.Jitter > 0 {
+		delay -= time.Duration(p.Jitter * rand.Float64() * float64(delay))
+	}
+	return delay
+}
+
+func (ws *wonService) retryable(method string, endpoint string) bool {
+	if ws.Retry.MaxAttempts < 2 {
+		return false
+	}
+	return method == "GET" || (endpoint == "api/v1/order/cancel" && ws.Retry.RetryCancel)
+}
+
+// temporary reports whether err is worth retrying, and how long the exchange
+// asked to wait before doing so.
+func temporary(ctx context.Context, err error) (bool, time.Duration) {
+	if ctx.Err() != nil {
+		return false, 0
+	}
+	switch e := err.(type) {
+	case *WonError:
+		return e.StatusCode >= 500 || e.StatusCode == http.StatusTooManyRequests, e.RetryAfter
+	case *url.Error:
+		return transient(e), 0
+	}
+	return false, 0
+}
+
+// transient reports whether a transport failure may pass on a new attempt:
+// a timeout, or a connection refused or reset. Failures such as a bad
+// certificate or an unsupported scheme are permanent.
+func transient(err *url.Error) bool {
+	if err.Timeout() {
+		return true
+	}
+	return errors.Is(err, syscall.ECONNREFUSED) || errors.Is(err, syscall.ECONNRESET)
+}
+
+// parseRetryAfter reads a Retry-After header given either in seconds or as
+// an HTTP date.
+func parseRetryAfter(value string, now time.Time) time.Duration {
+	if value == "" {
+		return 0
+	}
+	if seconds, err := strconv.Atoi(value); err == nil && seconds > 0 {
+		return time.Duration(seconds) * time.Second
+	}
+	if t, err := http.ParseTime(value); err == nil && t.After(now) {
+		return t.Sub(now)
+	}
+	return 0
+}
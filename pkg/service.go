@@ -1,6 +1,7 @@
 package pkg
 
 import (
+	"bytes"
 	"context"
 	"encoding/json"
 	"errors"
@@ -8,6 +9,7 @@ import (
 	"io/ioutil"
 	"net/http"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/go-kit/kit/log"
@@ -25,108 +27,196 @@ type Service interface {
 	GetOrders(OrdersRequest) ([]*Order, error)
 	GetOrder(OrderRequest) (*Order, error)
 	CancelOrder(CancelOrderRequest) error
+	CreateOrRecoverOrder(CreateOrderRequest) (*Order, error)
+	CreateOrders([]CreateOrderRequest) []OrderResult
+	CancelOrders(ids []int64) []CancelResult
+	CancelAll(market string, side Side) (*CancelReport, error)
+	CancelWhere(match func(*Order) bool) (*CancelReport, error)
+	AmendOrder(AmendOrderRequest) (*AmendResult, error)
+
+	TimeCtx(context.Context) (time.Time, error)
+	DepthCtx(context.Context, DepthRequest) (*DepthResult, error)
+	RecentTradesCtx(context.Context, TradeRequest) ([]*RecentTrade, error)
+	MyTradesCtx(context.Context, TradeRequest) ([]*MyTrade, error)
+	AccountCtx(context.Context, AccountRequest) (*Account, error)
+	TickerPriceCtx(context.Context, TickerPriceRequest) (*TickerPrice, error)
+	CreateOrderCtx(context.Context, CreateOrderRequest) (*Order, error)
+	GetOrdersCtx(context.Context, OrdersRequest) ([]*Order, error)
+	GetOrderCtx(context.Context, OrderRequest) (*Order, error)
+	CancelOrderCtx(context.Context, CancelOrderRequest) error
+	CreateOrRecoverOrderCtx(context.Context, CreateOrderRequest) (*Order, error)
+	CreateOrdersCtx(context.Context, []CreateOrderRequest) []OrderResult
+	CancelOrdersCtx(ctx context.Context, ids []int64) []CancelResult
+	CancelAllCtx(ctx context.Context, market string, side Side) (*CancelReport, error)
+	CancelWhereCtx(ctx context.Context, match func(*Order) bool) (*CancelReport, error)
+	AmendOrderCtx(context.Context, AmendOrderRequest) (*AmendResult, error)
+
+	// SyncTime measures the clock offset against the exchange; ClockOffset
+	// returns the last measurement. Close stops the background time sync
+	// started by WithTimeSync.
+	SyncTime(context.Context) error
+	ClockOffset() time.Duration
+	Close() error
+
+	// RateLimitBudget reports the state of a client-side rate limit bucket,
+	// or false when the bucket is not limited.
+	RateLimitBudget(bucket string) (Budget, bool)
+
+	// ExchangeInfo returns the trading rules of every market; MarketInfo
+	// those of a single market. Both are served from a cache, see
+	// WithMarketCacheTTL.
+	ExchangeInfo() (*ExchangeInfo, error)
+	ExchangeInfoCtx(context.Context) (*ExchangeInfo, error)
+	MarketInfo(market string) (*MarketInfo, error)
+	MarketInfoCtx(ctx context.Context, market string) (*MarketInfo, error)
 }
 
 type wonService struct {
-	URL    string
-	APIKey string
-	Signer Signer
-	Logger log.Logger
-	Ctx    context.Context
+	// clockOffset is accessed atomically and kept first for 64-bit alignment.
+	clockOffset int64
+
+	URL        string
+	APIKey     string
+	Signer     Signer
+	Logger     log.Logger
+	Ctx        context.Context
+	Client     *http.Client
+	UserAgent  string
+	RecvWindow int
+	Retry      RetryPolicy
+
+	clock            func() time.Time
+	timeSyncInterval time.Duration
+	stopTimeSync     context.CancelFunc
+	httpConfig       httpConfig
+	buckets          map[string]*TokenBucket
+	weights          map[string]int
+	rateLimitPolicy  RateLimitPolicy
+	markets          marketCache
+	validator        *OrderValidator
+	batch            batchConfig
+	signing          SigningScheme
+	body             BodyEncoding
+	// amend is 1 while the replace endpoint is in use, accessed atomically.
+	amend int32
 }
 
-func NewWonService(url, apiKey string, signer Signer, logger log.Logger, ctx context.Context) Service {
-	if logger == nil {
-		logger = log.NewNopLogger()
+// NewService returns a Service talking to the exchange at url, configured by
+// opts. Without options the service can only reach public endpoints.
+func NewService(url string, opts ...Option) Service {
+	ws := &wonService{
+		URL:        url,
+		RecvWindow: DefaultRecvWindow,
+		Retry:      DefaultRetryPolicy(),
+		clock:      time.Now,
+		httpConfig: defaultHTTPConfig(),
+		markets:    marketCache{ttl: DefaultMarketCacheTTL},
 	}
-	if ctx == nil {
-		ctx = context.Background()
+	for _, opt := range opts {
+		opt(ws)
 	}
-	return &wonService{
-		URL:    url,
-		APIKey: apiKey,
-		Signer: signer,
-		Logger: logger,
-		Ctx:    ctx,
+	if ws.Logger == nil {
+		ws.Logger = log.NewNopLogger()
 	}
+	if ws.Ctx == nil {
+		ws.Ctx = context.Background()
+	}
+	if ws.Client == nil {
+		ws.Client = newHTTPClient(ws.httpConfig)
+	}
+	if ws.timeSyncInterval > 0 {
+		ctx, cancel := context.WithCancel(ws.Ctx)
+		ws.stopTimeSync = cancel
+		go ws.syncTimeLoop(ctx, ws.timeSyncInterval)
+	}
+	return ws
+}
+
+// Deprecated: use NewService with WithAPIKey, WithSigner, WithLogger and
+// WithContext instead.
+func NewWonService(url, apiKey string, signer Signer, logger log.Logger, ctx context.Context, opts ...Option) Service {
+	base := []Option{WithAPIKey(apiKey), WithSigner(signer), WithLogger(logger), WithContext(ctx)}
+	return NewService(url, append(base, opts...)...)
 }
 
 func (ws *wonService) Time() (time.Time, error) {
-	params := make(map[string]string)
-	res, err := ws.request("GET", "api/v1/time", params, false, false)
-	if err != nil {
-		return time.Time{}, err
-	}
+	return ws.TimeCtx(ws.Ctx)
+}
+func (ws *wonService) Depth(dq DepthRequest) (*DepthResult, error) {
+	return ws.DepthCtx(ws.Ctx, dq)
+}
+func (ws *wonService) RecentTrades(tr TradeRequest) ([]*RecentTrade, error) {
+	return ws.RecentTradesCtx(ws.Ctx, tr)
+}
+func (ws *wonService) MyTrades(tr TradeRequest) ([]*MyTrade, error) {
+	return ws.MyTradesCtx(ws.Ctx, tr)
+}
+func (ws *wonService) Account(ar AccountRequest) (*Account, error) {
+	return ws.AccountCtx(ws.Ctx, ar)
+}
+func (ws *wonService) TickerPrice(tqr TickerPriceRequest) (*TickerPrice, error) {
+	return ws.TickerPriceCtx(ws.Ctx, tqr)
+}
+func (ws *wonService) CreateOrder(cor CreateOrderRequest) (*Order, error) {
+	return ws.CreateOrderCtx(ws.Ctx, cor)
+}
+func (ws *wonService) GetOrders(osr OrdersRequest) ([]*Order, error) {
+	return ws.GetOrdersCtx(ws.Ctx, osr)
+}
+func (ws *wonService) GetOrder(or OrderRequest) (*Order, error) {
+	return ws.GetOrderCtx(ws.Ctx, or)
+}
+func (ws *wonService) CancelOrder(cor CancelOrderRequest) error {
+	return ws.CancelOrderCtx(ws.Ctx, cor)
+}
 
-	textRes, err := ioutil.ReadAll(res.Body)
-	if err != nil {
-		return time.Time{}, errors.New(fmt.Sprintf("unable to read response from Time:%s", err.Error()))
-	}
-	defer res.Body.Close()
+func (ws *wonService) TimeCtx(ctx context.Context) (time.Time, error) {
+	params := make(map[string]string)
 
-	type data struct {
-		Time int64 `json:time`
-	}
 	var rawTime struct {
-		Date data `json:"data"`
+		Time int64 `json:"time"`
 	}
-	if err := json.Unmarshal(textRes, &rawTime); err != nil {
-		return time.Time{}, errors.New(fmt.Sprintf("Time Response unmarshal failed:%s", err.Error()))
+	if err := ws.call(ctx, "GET", "api/v1/time", params, false, false, &rawTime); err != nil {
+		return time.Time{}, err
 	}
-	t, err := timeFromUnixMillTimestamp(rawTime.Date.Time)
+	t, err := timeFromUnixMillTimestamp(rawTime.Time)
 	if err != nil {
 		return time.Time{}, err
 	}
 	return t, nil
 }
 
-func (ws *wonService) Depth(dq DepthRequest) (*DepthResult, error) {
+func (ws *wonService) DepthCtx(ctx context.Context, dq DepthRequest) (*DepthResult, error) {
 	params := make(map[string]string)
 	params["market"] = dq.Market
 	if dq.Limit > 0 {
 		params["limit"] = strconv.Itoa(dq.Limit)
 	}
 
-	res, err := ws.request("GET", "api/v1/depth", params, false, false)
-	if err != nil {
-		return nil, err
-	}
-
-	textRes, err := ioutil.ReadAll(res.Body)
-	if err != nil {
-		return nil, errors.New(fmt.Sprintf("unable to read response from Depth:%s", err.Error()))
-	}
-	defer res.Body.Close()
-
-	type result struct {
-		Time int
-		Bids [][]string
-		Asks [][]string
-	}
-
 	var rawDepth struct {
-		Data result `json:"data"`
+		Time         int
+		LastUpdateId int64 `json:"last_update_id"`
+		Bids         [][]string
+		Asks         [][]string
 	}
-
-	if err := json.Unmarshal(textRes, &rawDepth); err != nil {
-		return nil, errors.New(fmt.Sprintf("Depth Response unmarshal Depth:%s", err.Error()))
+	if err := ws.call(ctx, "GET", "api/v1/depth", params, false, false, &rawDepth); err != nil {
+		return nil, err
 	}
+
 	var resultDepth DepthResult
-	type depth struct {
-		Price  string
-		Amount string
-	}
-	for _, v := range rawDepth.Data.Bids {
-		resultDepth.Bids = append(resultDepth.Bids, depth{Price: v[0], Amount: v[1]})
+	var err error
+	if resultDepth.Bids, err = priceLevels(rawDepth.Bids); err != nil {
+		return nil, errors.New(fmt.Sprintf("Depth Response malformed bids:%s", err.Error()))
 	}
-	for _, v := range rawDepth.Data.Asks {
-		resultDepth.Asks = append(resultDepth.Asks, depth{Price: v[0], Amount: v[1]})
+	if resultDepth.Asks, err = priceLevels(rawDepth.Asks); err != nil {
+		return nil, errors.New(fmt.Sprintf("Depth Response malformed asks:%s", err.Error()))
 	}
-	resultDepth.Time = rawDepth.Data.Time
+	resultDepth.Time = rawDepth.Time
+	resultDepth.LastUpdateId = rawDepth.LastUpdateId
 
-	return &resultDepth, err
+	return &resultDepth, nil
 }
-func (ws *wonService) RecentTrades(tr TradeRequest) ([]*RecentTrade, error) {
+func (ws *wonService) RecentTradesCtx(ctx context.Context, tr TradeRequest) ([]*RecentTrade, error) {
 	params := make(map[string]string)
 	params["market"] = tr.Market
 	if tr.Limit > 0 {
@@ -137,39 +227,25 @@ func (ws *wonService) RecentTrades(tr TradeRequest) ([]*RecentTrade, error) {
 		params["from_id"] = strconv.FormatInt(tr.FromId, 10)
 	}
 
-	res, err := ws.request("GET", "api/v1/trades/recent", params, true, false)
-	if err != nil {
-		return nil, err
-	}
-
-	textRes, err := ioutil.ReadAll(res.Body)
-	if err != nil {
-		return nil, errors.New(fmt.Sprintf("unable to read response from Depth:%s", err.Error()))
-	}
-	defer res.Body.Close()
-
 	type result struct {
-		Id       int64  `json:"id"`
-		Price    string `json:"price"`
-		Quantity string `json:"qty"`
-		CreateAt int64  `json:"time"`
+		Id       int64   `json:"id"`
+		Price    Decimal `json:"price"`
+		Quantity Decimal `json:"qty"`
+		CreateAt int64   `json:"time"`
 	}
 
-	var rawDepth struct {
-		Data []result `json:"data"`
-	}
-
-	if err := json.Unmarshal(textRes, &rawDepth); err != nil {
-		return nil, errors.New(fmt.Sprintf("Trades Response unmarshal Trades:%s", err.Error()))
+	var rawTrades []result
+	if err := ws.call(ctx, "GET", "api/v1/trades/recent", params, true, false, &rawTrades); err != nil {
+		return nil, err
 	}
 	var trades []*RecentTrade
-	for _, v := range rawDepth.Data {
+	for _, v := range rawTrades {
 		trades = append(trades, &RecentTrade{Id: v.Id, Price: v.Price, Quantity: v.Quantity, CreateAt: v.CreateAt})
 	}
 
 	return trades, nil
 }
-func (ws *wonService) MyTrades(tr TradeRequest) ([]*MyTrade, error) {
+func (ws *wonService) MyTradesCtx(ctx context.Context, tr TradeRequest) ([]*MyTrade, error) {
 	params := make(map[string]string)
 	params["market"] = tr.Market
 	if tr.Limit > 0 {
@@ -179,317 +255,298 @@ func (ws *wonService) MyTrades(tr TradeRequest) ([]*MyTrade, error) {
 	if tr.FromId > 0 {
 		params["from_id"] = strconv.FormatInt(tr.FromId, 10)
 	}
+	ws.stamp(params, 0, 0)
 
-	res, err := ws.request("GET", "api/v1/trades/my", params, true, true)
-	if err != nil {
+	var rawTrades []rawMyTrade
+	if err := ws.call(ctx, "GET", "api/v1/trades/my", params, true, true, &rawTrades); err != nil {
 		return nil, err
 	}
-
-	textRes, err := ioutil.ReadAll(res.Body)
-	if err != nil {
-		return nil, errors.New(fmt.Sprintf("unable to read response from HistoricalTrades:%s", err.Error()))
-	}
-	defer res.Body.Close()
-
-	type result struct {
-		Id       int64  `json:"id"`
-		OrderId  int64  `json:"order_id"`
-		Price    string `json:"price"`
-		Side     string `json:"side"`
-		Quantity string `json:"qty"`
-		CreateAt int64  `json:"time"`
-	}
-
-	var rawDepth struct {
-		Data []result `json:"data"`
-	}
-
-	if err := json.Unmarshal(textRes, &rawDepth); err != nil {
-		return nil, errors.New(fmt.Sprintf("HistoricalTrades Response unmarshal HistoricalTrades:%s", err.Error()))
-	}
 	var trades []*MyTrade
-	for _, v := range rawDepth.Data {
-		trades = append(trades, &MyTrade{Id: v.Id, OrderId: v.OrderId, Price: v.Price, Side: v.Side, Quantity: v.Quantity, CreateAt: v.CreateAt})
+	for _, v := range rawTrades {
+		trade := v.myTrade()
+		trades = append(trades, &trade)
 	}
 
 	return trades, nil
 }
-func (ws *wonService) Account(ar AccountRequest) (*Account, error) {
+func (ws *wonService) AccountCtx(ctx context.Context, ar AccountRequest) (*Account, error) {
 	params := make(map[string]string)
-	params["timestamp"] = strconv.FormatInt(ar.Timestamp, 10)
-	if ar.RecvWindow > 0 {
-		params["recv_window"] = strconv.Itoa(ar.RecvWindow)
-	}
-	res, err := ws.request("GET", "api/v1/account", params, true, true)
-	if err != nil {
-		return nil, err
-	}
-
-	textRes, err := ioutil.ReadAll(res.Body)
-	if err != nil {
-		return nil, errors.New(fmt.Sprintf("unable to read response from Account:%s", err.Error()))
-	}
-	defer res.Body.Close()
-
-	if res.StatusCode != 200 {
-		return nil, ws.handleError(textRes)
-	}
-
-	type CA struct {
-		Currency     string `json:"currency"`
-		TotalBalance string `json:"total_balance"`
-		Balance      string `json:"balance"`
-		Locked       string `json:"locked"`
-		UsdPrice     string `json:"usd_price"`
-		Precision    int    `json:"precision"`
-		Limits       struct {
-			MinimalTradeFee string `json:"minimal_trade_fee"`
-		} `json:"limits"`
-	}
+	ws.stamp(params, ar.Timestamp, ar.RecvWindow)
 
 	var rawResult struct {
-		Data struct {
-			Accounts      []CA   `json:"accounts"`
-			EqualTotalUsd string `json:"equal_total_usd"`
-		} `json:"data"`
+		Accounts      []rawCurrencyAccount `json:"accounts"`
+		EqualTotalUsd Decimal              `json:"equal_total_usd"`
 	}
-	if err := json.Unmarshal(textRes, &rawResult); err != nil {
-		return nil, errors.New(fmt.Sprintf("Account Response unmarshal failed:%s", err.Error()))
+	if err := ws.call(ctx, "GET", "api/v1/account", params, true, true, &rawResult); err != nil {
+		return nil, err
 	}
 
 	account := &Account{}
-	account.EqualTotalUsd = rawResult.Data.EqualTotalUsd
-	for _, v := range rawResult.Data.Accounts {
-		account.Accounts = append(account.Accounts, CurrencyAccount{
-			Currency:     v.Currency,
-			TotalBalance: v.TotalBalance,
-			Balance:      v.Balance,
-			Locked:       v.Locked,
-			UsdPrice:     v.UsdPrice,
-			Precision:    v.Precision,
-			Limits:       struct{ MinimalTradeFee string }{MinimalTradeFee: v.Limits.MinimalTradeFee},
-		})
+	account.EqualTotalUsd = rawResult.EqualTotalUsd
+	for _, v := range rawResult.Accounts {
+		account.Accounts = append(account.Accounts, v.currencyAccount())
 	}
 
 	return account, nil
 }
-func (ws *wonService) TickerPrice(tqr TickerPriceRequest) (*TickerPrice, error) {
+func (ws *wonService) TickerPriceCtx(ctx context.Context, tqr TickerPriceRequest) (*TickerPrice, error) {
 	params := make(map[string]string)
 	params["market"] = tqr.Market
 
-	res, err := ws.request("GET", "api/v1/ticker/price", params, false, false)
-	if err != nil {
+	var tickerPrice TickerPrice
+	if err := ws.call(ctx, "GET", "api/v1/ticker/price", params, false, false, &tickerPrice); err != nil {
 		return nil, err
 	}
 
-	textRes, err := ioutil.ReadAll(res.Body)
-	if err != nil {
-		return nil, errors.New(fmt.Sprintf("unable to read response from TickerPrice:%s", err.Error()))
-	}
-	defer res.Body.Close()
-
-	var rawDepth struct {
-		Data TickerPrice `json:"data"`
-	}
-
-	if err := json.Unmarshal(textRes, &rawDepth); err != nil {
-		return nil, errors.New(fmt.Sprintf("TickerPrice Response unmarshal TickerPrice:%s", err.Error()))
-	}
-
-	return &rawDepth.Data, nil
+	return &tickerPrice, nil
 }
 
-func (ws *wonService) CreateOrder(cor CreateOrderRequest) (*Order, error) {
-	params := make(map[string]string)
-	params["market"] = cor.Market
-	params["side"] = cor.Side
-	params["volume"] = cor.Volume
-	params["price"] = cor.Price
-	params["ord_type"] = cor.OrdType
-	params["timestamp"] = strconv.FormatInt(cor.Timestamp, 10)
-	if cor.RecvWindow > 0 {
-		params["recv_window"] = strconv.Itoa(cor.RecvWindow)
-	}
-	res, err := ws.request("POST", "api/v1/order/create", params, true, true)
+func (ws *wonService) CreateOrderCtx(ctx context.Context, cor CreateOrderRequest) (*Order, error) {
+	cor, err := ws.prepareOrder(ctx, cor)
 	if err != nil {
 		return nil, err
 	}
+	return ws.sendOrder(ctx, cor)
+}
 
-	textRes, err := ioutil.ReadAll(res.Body)
-	if err != nil {
-		return nil, errors.New(fmt.Sprintf("unable to read response from CreateOrder:%s", err.Error()))
-	}
-	defer res.Body.Close()
-
-	if res.StatusCode >= 300 {
-		return nil, ws.handleError(textRes)
-	}
+// sendOrder creates a prepared order.
+func (ws *wonService) sendOrder(ctx context.Context, cor CreateOrderRequest) (*Order, error) {
+	params := orderParams(cor)
+	ws.stamp(params, cor.Timestamp, cor.RecvWindow)
 
-	var rawResult struct {
-		Data Order `json:"data"`
+	var order Order
+	if err := ws.call(ctx, "POST", "api/v1/order/create", params, true, true, &order); err != nil {
+		return nil, err
 	}
-	if err := json.Unmarshal(textRes, &rawResult); err != nil {
-		return nil, errors.New(fmt.Sprintf("CreateOrder Response unmarshal failed:%s", err.Error()))
+	if order.ClientOrderId == "" {
+		order.ClientOrderId = cor.ClientOrderId
 	}
 
-	return &rawResult.Data, nil
+	return &order, nil
 }
-func (ws *wonService) GetOrders(osr OrdersRequest) ([]*Order, error) {
+func (ws *wonService) GetOrdersCtx(ctx context.Context, osr OrdersRequest) ([]*Order, error) {
 	params := make(map[string]string)
 	params["market"] = osr.Market
 	params["order_id"] = strconv.FormatInt(osr.OrderId, 10)
 	params["start_at_stamp"] = strconv.FormatInt(osr.StartAtStamp, 10)
 	params["end_at_stamp"] = strconv.FormatInt(osr.EndAtStamp, 10)
-	params["timestamp"] = strconv.FormatInt(osr.Timestamp, 10)
+	ws.stamp(params, osr.Timestamp, osr.RecvWindow)
 
 	if len(osr.State) > 0 {
-		params["state"] = osr.State
+		params["state"] = string(osr.State)
 	}
 	if len(osr.Side) > 0 {
-		params["side"] = osr.Side
+		params["side"] = string(osr.Side)
 	}
 	if osr.Limit > 0 {
 		params["limit"] = strconv.Itoa(osr.Limit)
 	}
-	if osr.RecvWindow > 0 {
-		params["recv_window"] = strconv.Itoa(osr.RecvWindow)
-	}
-	res, err := ws.request("GET", "api/v1/orders", params, true, true)
-	if err != nil {
-		return nil, err
-	}
 
-	textRes, err := ioutil.ReadAll(res.Body)
-	if err != nil {
-		return nil, errors.New(fmt.Sprintf("unable to read response from GetOrder:%s", err.Error()))
-	}
-	defer res.Body.Close()
-
-	if res.StatusCode != 200 {
-		return nil, ws.handleError(textRes)
-	}
-
-	var rawResult struct {
-		Data []Order `json:"data"`
-	}
-	if err := json.Unmarshal(textRes, &rawResult); err != nil {
-		return nil, errors.New(fmt.Sprintf("GetOrder Response unmarshal failed:%s", err.Error()))
+	var rawOrders []Order
+	if err := ws.call(ctx, "GET", "api/v1/orders", params, true, true, &rawOrders); err != nil {
+		return nil, err
 	}
 	var orders []*Order
-	for i, _ := range rawResult.Data {
-		orders = append(orders, &rawResult.Data[i])
+	for i := range rawOrders {
+		orders = append(orders, &rawOrders[i])
 	}
 	return orders, nil
 }
-func (ws *wonService) GetOrder(or OrderRequest) (*Order, error) {
+func (ws *wonService) GetOrderCtx(ctx context.Context, or OrderRequest) (*Order, error) {
 	params := make(map[string]string)
-	params["id"] = strconv.FormatInt(or.Id, 10)
-	params["timestamp"] = strconv.FormatInt(or.Timestamp, 10)
-	if or.RecvWindow > 0 {
-		params["recv_window"] = strconv.Itoa(or.RecvWindow)
+	if or.Id == 0 && or.ClientOrderId != "" {
+		params["client_order_id"] = or.ClientOrderId
+	} else {
+		params["id"] = strconv.FormatInt(or.Id, 10)
 	}
-	res, err := ws.request("GET", "api/v1/order", params, true, true)
-	if err != nil {
+	ws.stamp(params, or.Timestamp, or.RecvWindow)
+
+	var order Order
+	if err := ws.call(ctx, "GET", "api/v1/order", params, true, true, &order); err != nil {
 		return nil, err
 	}
 
-	textRes, err := ioutil.ReadAll(res.Body)
-	if err != nil {
-		return nil, errors.New(fmt.Sprintf("unable to read response from GetOrder:%s", err.Error()))
-	}
-	defer res.Body.Close()
+	return &order, nil
+}
 
-	if res.StatusCode != 200 {
-		return nil, ws.handleError(textRes)
-	}
+func (ws *wonService) CancelOrderCtx(ctx context.Context, cor CancelOrderRequest) error {
+	params := make(map[string]string)
+	params["id"] = strconv.FormatInt(cor.Id, 10)
+	ws.stamp(params, cor.Timestamp, cor.RecvWindow)
 
-	var rawResult struct {
-		Data Order `json:"data"`
+	var result string
+	if err := ws.call(ctx, "POST", "api/v1/order/cancel", params, true, true, &result); err != nil {
+		return err
 	}
-	if err := json.Unmarshal(textRes, &rawResult); err != nil {
-		return nil, errors.New(fmt.Sprintf("GetOrder Response unmarshal failed:%s", err.Error()))
+	if result != "success" {
+		return errors.New(fmt.Sprintf("CancelOrder unexpected result:%s", result))
 	}
 
-	return &rawResult.Data, nil
+	return nil
 }
 
-func (ws *wonService) CancelOrder(cor CancelOrderRequest) error {
-	params := make(map[string]string)
-	params["id"] = strconv.FormatInt(cor.Id, 10)
-	params["timestamp"] = strconv.FormatInt(cor.Timestamp, 10)
-	if cor.RecvWindow > 0 {
-		params["recv_window"] = strconv.Itoa(cor.RecvWindow)
+// call sends the request, checks the response status and decodes the data
+// member of the response envelope into out. Any failure reported by the
+// exchange is returned as a *WonError. Temporary failures are retried
+// according to the retry policy.
+func (ws *wonService) call(ctx context.Context, method string, endpoint string, params map[string]string,
+	apiKey bool, sign bool, out interface{}) error {
+	attempts := 1
+	if ws.retryable(method, endpoint) {
+		attempts = ws.Retry.MaxAttempts
+	}
+	for attempt := 0; ; attempt++ {
+		if _, ok := params["timestamp"]; ok && attempt > 0 && sign {
+			params["timestamp"] = strconv.FormatInt(ws.timestamp(), 10)
+		}
+		err := ws.do(ctx, method, endpoint, params, apiKey, sign, out)
+		if err == nil || attempt+1 >= attempts {
+			return err
+		}
+		retry, retryAfter := temporary(ctx, err)
+		if !retry {
+			return err
+		}
+		delay := ws.Retry.backoff(attempt, retryAfter)
+		level.Debug(ws.Logger).Log("path", endpoint, "attempt", attempt+1, "retryIn", delay, "err", err)
+		select {
+		case <-ctx.Done():
+			return err
+		case <-time.After(delay):
+		}
 	}
-	res, err := ws.request("POST", "api/v1/order/cancel", params, true, true)
+}
+
+// emptyDataEndpoints are the endpoints answering an empty result with a
+// missing or null data member. Any other endpoint doing so is an error.
+var emptyDataEndpoints = map[string]bool{
+	"api/v1/orders":        true,
+	"api/v1/trades/my":     true,
+	"api/v1/trades/recent": true,
+}
+
+func (ws *wonService) do(ctx context.Context, method string, endpoint string, params map[string]string,
+	apiKey bool, sign bool, out interface{}) error {
+	if err := ws.limit(ctx, endpoint, sign); err != nil {
+		return err
+	}
+	res, err := ws.request(ctx, method, endpoint, params, apiKey, sign)
 	if err != nil {
 		return err
 	}
+	defer res.Body.Close()
 
 	textRes, err := ioutil.ReadAll(res.Body)
 	if err != nil {
-		return errors.New(fmt.Sprintf("unable to read response from CancelOrder:%s", err.Error()))
+		return errors.New(fmt.Sprintf("unable to read response from %s:%s", endpoint, err.Error()))
 	}
-	defer res.Body.Close()
 
-	if res.StatusCode >= 300 {
-		return ws.handleError(textRes)
+	if res.StatusCode < 200 || res.StatusCode >= 300 {
+		err := ws.handleError(endpoint, res.StatusCode, textRes)
+		err.RetryAfter = parseRetryAfter(res.Header.Get("Retry-After"), ws.clock())
+		return err
 	}
 
-	var rawResult struct {
-		Data string `json:"data"`
+	var envelope struct {
+		Data  json.RawMessage `json:"data"`
+		Error string          `json:"error"`
 	}
-	if err := json.Unmarshal(textRes, &rawResult); err != nil || rawResult.Data != "success" {
-		return errors.New(fmt.Sprintf("CancelOrder Response unmarshal failed:%s", err.Error()))
+	if err := json.Unmarshal(textRes, &envelope); err != nil {
+		return errors.New(fmt.Sprintf("%s response unmarshal failed:%s", endpoint, err.Error()))
+	}
+	if envelope.Error != "" {
+		return ws.handleError(endpoint, res.StatusCode, textRes)
+	}
+	if len(envelope.Data) == 0 || bytes.Equal(envelope.Data, []byte("null")) {
+		if emptyDataEndpoints[endpoint] {
+			return nil
+		}
+		return errors.New(fmt.Sprintf("%s response has no data", endpoint))
+	}
+	if err := json.Unmarshal(envelope.Data, out); err != nil {
+		return errors.New(fmt.Sprintf("%s response unmarshal failed:%s", endpoint, err.Error()))
 	}
-
 	return nil
 }
 
-func (ws *wonService) request(method string, endpoint string, params map[string]string,
+func (ws *wonService) request(ctx context.Context, method string, endpoint string, params map[string]string,
 	apiKey bool, sign bool) (*http.Response, error) {
-	transport := &http.Transport{}
-	client := &http.Client{
-		Transport: transport,
+	req, err := ws.newRequest(ctx, method, endpoint, params, apiKey, sign)
+	if err != nil {
+		return nil, err
 	}
-
-	url := fmt.Sprintf("%s/%s", ws.URL, endpoint)
-	req, err := http.NewRequest(method, url, nil)
+	resp, err := ws.Client.Do(req)
 	if err != nil {
-		return nil, errors.New(fmt.Sprintf("create request error:%s", err.Error()))
+		return nil, err
 	}
-	req.WithContext(ws.Ctx)
-
-	q := req.URL.Query()
+	return resp, nil
+}
 
-	for key, val := range params {
-		q.Add(key, val)
+// stamp adds the timestamp and recv_window parameters of a signed request,
+// falling back to the synchronized service clock and default recv window when
+// the caller left them zero.
+func (ws *wonService) stamp(params map[string]string, timestamp int64, recvWindow int) {
+	if timestamp == 0 {
+		timestamp = ws.timestamp()
 	}
-	if apiKey {
-		req.Header.Add("X-Won-Apikey", ws.APIKey)
+	params["timestamp"] = strconv.FormatInt(timestamp, 10)
+	if recvWindow == 0 {
+		recvWindow = ws.RecvWindow
 	}
-	if sign {
-		level.Debug(ws.Logger).Log("queryString", q.Encode())
-		q.Add("signature", ws.Signer.Sign([]byte(q.Encode())))
-		level.Debug(ws.Logger).Log("signature", ws.Signer.Sign([]byte(q.Encode())))
+	if recvWindow > 0 {
+		params["recv_window"] = strconv.Itoa(recvWindow)
 	}
-	req.URL.RawQuery = q.Encode()
-	req.Close = true
+}
 
-	resp, err := client.Do(req)
-	if err != nil {
-		return nil, err
+func (ws *wonService) handleError(endpoint string, status int, textRes []byte) *WonError {
+	err := &WonError{StatusCode: status, Path: endpoint, Body: textRes}
+	level.Info(ws.Logger).Log("path", endpoint, "status", status, "errorResponse", textRes)
+
+	if json.Unmarshal(textRes, err) != nil || err.Code == "" {
+		err.Code = strconv.Itoa(status)
+		err.Message = strings.TrimSpace(string(textRes))
+		if err.Message == "" {
+			err.Message = http.StatusText(status)
+		}
 	}
-	return resp, nil
+	return err
 }
 
-func (ws *wonService) handleError(textRes []byte) error {
-	err := &WonError{}
-	level.Info(ws.Logger).Log("errorResponse", textRes)
+type rawMyTrade struct {
+	Id       int64   `json:"id"`
+	OrderId  int64   `json:"order_id"`
+	Price    Decimal `json:"price"`
+	Side     Side    `json:"side"`
+	Quantity Decimal `json:"qty"`
+	CreateAt int64   `json:"time"`
+}
 
-	if err := json.Unmarshal(textRes, err); err != nil {
-		return errors.New(fmt.Sprintf("error unmarshal failed:%s", err.Error()))
+func (v rawMyTrade) myTrade() MyTrade {
+	return MyTrade{Id: v.Id, OrderId: v.OrderId, Price: v.Price, Side: v.Side, Quantity: v.Quantity, CreateAt: v.CreateAt}
+}
+
+type rawCurrencyAccount struct {
+	Currency     string  `json:"currency"`
+	TotalBalance Decimal `json:"total_balance"`
+	Balance      Decimal `json:"balance"`
+	Locked       Decimal `json:"locked"`
+	UsdPrice     Decimal `json:"usd_price"`
+	Precision    int     `json:"precision"`
+	Limits       struct {
+		MinimalTradeFee Decimal `json:"minimal_trade_fee"`
+	} `json:"limits"`
+}
+
+func (v rawCurrencyAccount) currencyAccount() CurrencyAccount {
+	return CurrencyAccount{
+		Currency:     v.Currency,
+		TotalBalance: v.TotalBalance,
+		Balance:      v.Balance,
+		Locked:       v.Locked,
+		UsdPrice:     v.UsdPrice,
+		Precision:    v.Precision,
+		Limits:       struct{ MinimalTradeFee Decimal }{MinimalTradeFee: v.Limits.MinimalTradeFee},
 	}
-	return err
 }
 
 func timeFromUnixMillTimestamp(ts int64) (time.Time, error) {
@@ -0,0 +1,419 @@
+package pkg
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-kit/kit/log"
+	"github.com/go-kit/kit/log/level"
+	"github.com/gorilla/websocket"
+)
+
+// Channel is a kind of market data pushed by the stream.
+type Channel string
+
+const (
+	DepthChannel  Channel = "depth"
+	TradeChannel  Channel = "trade"
+	TickerChannel Channel = "ticker"
+)
+
+// DepthUpdate carries the order book changes of a market between
+// FirstUpdateId and LastUpdateId, both inclusive.
+type DepthUpdate struct {
+	Market        string
+	FirstUpdateId int64
+	LastUpdateId  int64
+	Time          int64
+	Bids          []PriceLevel
+	Asks          []PriceLevel
+}
+
+type TradeEvent struct {
+	Market string
+	RecentTrade
+}
+
+type TickerEvent struct {
+	Market string
+	Price  Decimal
+	Time   int64
+}
+
+// MarketHandler receives the events of a MarketStream. Nil callbacks are
+// skipped. Callbacks run on the stream goroutine and must not block.
+type MarketHandler struct {
+	OnDepth  func(DepthUpdate)
+	OnTrade  func(TradeEvent)
+	OnTicker func(TickerEvent)
+	// OnDisconnect is called with the cause whenever the connection is lost,
+	// before reconnecting.
+	OnDisconnect func(error)
+}
+
+// StreamOption configures a MarketStream or a UserStream.
+type StreamOption func(*stream)
+
+// WithStreamLogger sets the logger of the stream.
+func WithStreamLogger(logger log.Logger) StreamOption {
+	return func(s *stream) {
+		s.logger = logger
+	}
+}
+
+// WithStreamDialer sets the dialer used to open websocket connections.
+func WithStreamDialer(dialer *websocket.Dialer) StreamOption {
+	return func(s *stream) {
+		s.dialer = dialer
+	}
+}
+
+// WithStreamClock sets the clock stamping the login of a UserStream. It
+// defaults to time.Now; pass the clock of a synchronized service, such as
+// func() time.Time { return time.Now().Add(service.ClockOffset()) }, on a
+// host whose clock may be off.
+func WithStreamClock(now func() time.Time) StreamOption {
+	return func(s *stream) {
+		s.clock = now
+	}
+}
+
+// WithHeartbeat sets how often the stream pings the server and how long it
+// waits for any frame, pongs included, before dropping the connection as
+// dead. The timeout also bounds the UserStream login. It defaults to pings
+// every 20s and a 60s timeout.
+func WithHeartbeat(interval, timeout time.Duration) StreamOption {
+	return func(s *stream) {
+		s.pingInterval = interval
+		s.readTimeout = timeout
+	}
+}
+
+// WithReconnectDelay sets the delay before the first reconnection attempt;
+// it doubles on every failed attempt up to max.
+func WithReconnectDelay(delay, max time.Duration) StreamOption {
+	return func(s *stream) {
+		s.reconnect = RetryPolicy{BaseDelay: delay, MaxDelay: max, Jitter: 0.2}
+	}
+}
+
+// stream keeps a websocket connection open until its context is done,
+// reconnecting with backoff whenever it drops.
+type stream struct {
+	url       string
+	logger    log.Logger
+	dialer    *websocket.Dialer
+	reconnect RetryPolicy
+	clock     func() time.Time
+
+	pingInterval time.Duration
+	readTimeout  time.Duration
+
+	mu   sync.Mutex
+	conn *websocket.Conn
+
+	// onConnect runs on every new connection before messages are read.
+	onConnect    func(*websocket.Conn) error
+	onMessage    func([]byte) error
+	onDisconnect func(error)
+}
+
+func newStream(url string, opts []StreamOption) *stream {
+	s := &stream{
+		url:       url,
+		dialer:    websocket.DefaultDialer,
+		clock:     time.Now,
+		reconnect: RetryPolicy{BaseDelay: 500 * time.Millisecond, MaxDelay: 30 * time.Second, Jitter: 0.2},
+
+		pingInterval: 20 * time.Second,
+		readTimeout:  60 * time.Second,
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	if s.logger == nil {
+		s.logger = log.NewNopLogger()
+	}
+	return s
+}
+
+func (s *stream) run(ctx context.Context) error {
+	for attempt := 0; ; attempt++ {
+		connected, err := s.session(ctx)
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		if connected {
+			attempt = 0
+		}
+		level.Info(s.logger).Log("msg", "stream disconnected", "url", s.url, "err", err)
+		if s.onDisconnect != nil {
+			s.onDisconnect(err)
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(s.reconnect.backoff(attempt, 0)):
+		}
+	}
+}
+
+// session serves a single connection until it fails. It reports whether the
+// connection was established and set up.
+func (s *stream) session(ctx context.Context) (bool, error) {
+	conn, _, err := s.dialer.DialContext(ctx, s.url, nil)
+	if err != nil {
+		return false, err
+	}
+	defer conn.Close()
+
+	// A half-open connection never fails a read: every frame, pongs
+	// included, pushes the read deadline back, and pings make sure the
+	// server has something to answer.
+	conn.SetReadDeadline(time.Now().Add(s.readTimeout))
+	conn.SetPongHandler(func(string) error {
+		return conn.SetReadDeadline(time.Now().Add(s.readTimeout))
+	})
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		ping := time.NewTicker(s.pingInterval)
+		defer ping.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				conn.Close()
+				return
+			case <-done:
+				return
+			case <-ping.C:
+				if err := conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(s.readTimeout)); err != nil {
+					level.Debug(s.logger).Log("msg", "stream ping failed", "err", err)
+				}
+			}
+		}
+	}()
+
+	s.mu.Lock()
+	s.conn = conn
+	s.mu.Unlock()
+	defer func() {
+		s.mu.Lock()
+		s.conn = nil
+		s.mu.Unlock()
+	}()
+
+	// A connection failing its setup, such as a rejected login, counts as
+	// a failed attempt so that the reconnect delay keeps growing.
+	if s.onConnect != nil {
+		if err := s.onConnect(conn); err != nil {
+			return false, err
+		}
+	}
+	for {
+		conn.SetReadDeadline(time.Now().Add(s.readTimeout))
+		_, message, err := conn.ReadMessage()
+		if err != nil {
+			return true, err
+		}
+		if err := s.onMessage(message); err != nil {
+			level.Warn(s.logger).Log("msg", "dropping stream message", "err", err)
+		}
+	}
+}
+
+// send writes v to the current connection, if any. It reports whether a
+// connection was open.
+func (s *stream) send(v interface{}) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.conn == nil {
+		return false, nil
+	}
+	return true, s.conn.WriteJSON(v)
+}
+
+func (s *stream) sendOn(conn *websocket.Conn, v interface{}) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return conn.WriteJSON(v)
+}
+
+type streamRequest struct {
+	Method string   `json:"method"`
+	Params []string `json:"params"`
+}
+
+type streamMessage struct {
+	Stream string          `json:"stream"`
+	Data   json.RawMessage `json:"data"`
+}
+
+// MarketStream subscribes to market data over websocket. Subscriptions are
+// restored after every reconnection.
+type MarketStream struct {
+	handler MarketHandler
+	stream  *stream
+
+	mu      sync.Mutex
+	streams map[string]bool
+}
+
+// NewMarketStream returns a stream reading from the websocket endpoint at
+// url. Nothing is sent until Run is called.
+func NewMarketStream(url string, handler MarketHandler, opts ...StreamOption) *MarketStream {
+	ms := &MarketStream{
+		handler: handler,
+		streams: make(map[string]bool),
+	}
+	ms.stream = newStream(url, opts)
+	ms.stream.onConnect = ms.resubscribe
+	ms.stream.onMessage = ms.dispatch
+	ms.stream.onDisconnect = handler.OnDisconnect
+	return ms
+}
+
+// Run connects and delivers events until ctx is done, reconnecting whenever
+// the connection drops. It always returns ctx.Err().
+func (ms *MarketStream) Run(ctx context.Context) error {
+	return ms.stream.run(ctx)
+}
+
+func streamNames(markets []string, channels []Channel) []string {
+	var names []string
+	for _, m := range markets {
+		for _, c := range channels {
+			names = append(names, fmt.Sprintf("%s@%s", m, c))
+		}
+	}
+	return names
+}
+
+// Subscribe adds the given channels of markets to the subscriptions.
+func (ms *MarketStream) Subscribe(markets []string, channels ...Channel) error {
+	names := streamNames(markets, channels)
+	ms.mu.Lock()
+	for _, n := range names {
+		ms.streams[n] = true
+	}
+	ms.mu.Unlock()
+	_, err := ms.stream.send(streamRequest{Method: "subscribe", Params: names})
+	return err
+}
+
+// Unsubscribe removes the given channels of markets from the subscriptions.
+func (ms *MarketStream) Unsubscribe(markets []string, channels ...Channel) error {
+	names := streamNames(markets, channels)
+	ms.mu.Lock()
+	for _, n := range names {
+		delete(ms.streams, n)
+	}
+	ms.mu.Unlock()
+	_, err := ms.stream.send(streamRequest{Method: "unsubscribe", Params: names})
+	return err
+}
+
+func (ms *MarketStream) resubscribe(conn *websocket.Conn) error {
+	ms.mu.Lock()
+	var names []string
+	for n := range ms.streams {
+		names = append(names, n)
+	}
+	ms.mu.Unlock()
+	if len(names) == 0 {
+		return nil
+	}
+	return ms.stream.sendOn(conn, streamRequest{Method: "subscribe", Params: names})
+}
+
+func (ms *MarketStream) dispatch(message []byte) error {
+	var msg streamMessage
+	if err := json.Unmarshal(message, &msg); err != nil {
+		return errors.New(fmt.Sprintf("stream message unmarshal failed:%s", err.Error()))
+	}
+	if msg.Stream == "" {
+		// Subscription acknowledgements carry no stream.
+		return nil
+	}
+	at := strings.LastIndex(msg.Stream, "@")
+	if at < 0 {
+		return errors.New(fmt.Sprintf("unknown stream:%s", msg.Stream))
+	}
+	market := msg.Stream[:at]
+
+	switch Channel(msg.Stream[at+1:]) {
+	case DepthChannel:
+		var raw struct {
+			FirstUpdateId int64      `json:"first_update_id"`
+			LastUpdateId  int64      `json:"last_update_id"`
+			Time          int64      `json:"time"`
+			Bids          [][]string `json:"bids"`
+			Asks          [][]string `json:"asks"`
+		}
+		if err := json.Unmarshal(msg.Data, &raw); err != nil {
+			return errors.New(fmt.Sprintf("depth event unmarshal failed:%s", err.Error()))
+		}
+		update := DepthUpdate{Market: market, FirstUpdateId: raw.FirstUpdateId, LastUpdateId: raw.LastUpdateId, Time: raw.Time}
+		var err error
+		if update.Bids, err = priceLevels(raw.Bids); err != nil {
+			return err
+		}
+		if update.Asks, err = priceLevels(raw.Asks); err != nil {
+			return err
+		}
+		if ms.handler.OnDepth != nil {
+			ms.handler.OnDepth(update)
+		}
+	case TradeChannel:
+		var raw struct {
+			Id       int64   `json:"id"`
+			Price    Decimal `json:"price"`
+			Quantity Decimal `json:"qty"`
+			CreateAt int64   `json:"time"`
+		}
+		if err := json.Unmarshal(msg.Data, &raw); err != nil {
+			return errors.New(fmt.Sprintf("trade event unmarshal failed:%s", err.Error()))
+		}
+		if ms.handler.OnTrade != nil {
+			ms.handler.OnTrade(TradeEvent{Market: market, RecentTrade: RecentTrade{Id: raw.Id, Price: raw.Price, Quantity: raw.Quantity, CreateAt: raw.CreateAt}})
+		}
+	case TickerChannel:
+		var raw struct {
+			Price Decimal `json:"price"`
+			Time  int64   `json:"time"`
+		}
+		if err := json.Unmarshal(msg.Data, &raw); err != nil {
+			return errors.New(fmt.Sprintf("ticker event unmarshal failed:%s", err.Error()))
+		}
+		if ms.handler.OnTicker != nil {
+			ms.handler.OnTicker(TickerEvent{Market: market, Price: raw.Price, Time: raw.Time})
+		}
+	default:
+		return errors.New(fmt.Sprintf("unknown stream:%s", msg.Stream))
+	}
+	return nil
+}
+
+func priceLevels(raw [][]string) ([]PriceLevel, error) {
+	levels := make([]PriceLevel, 0, len(raw))
+	for _, v := range raw {
+		if len(v) < 2 {
+			return nil, errors.New(fmt.Sprintf("malformed price level:%v", v))
+		}
+		price, err := NewDecimal(v[0])
+		if err != nil {
+			return nil, err
+		}
+		amount, err := NewDecimal(v[1])
+		if err != nil {
+			return nil, err
+		}
+		levels = append(levels, PriceLevel{Price: price, Amount: amount})
+	}
+	return levels, nil
+}
@@ -0,0 +1,77 @@
+package pkg
+
+import (
+	"context"
+	"sync/atomic"
+	"time"
+
+	"github.com/go-kit/kit/log/level"
+)
+
+// DefaultRecvWindow is the recv_window, in milliseconds, sent with signed
+// requests when neither the request nor WithRecvWindow sets one.
+const DefaultRecvWindow = 5000
+
+// WithTimeSync makes the service measure its clock offset against the
+// exchange every interval, from a goroutine that runs until Close is called
+// or the context set by WithContext is done. Signed requests are then
+// stamped with the corrected time.
+func WithTimeSync(interval time.Duration) Option {
+	return func(ws *wonService) {
+		ws.timeSyncInterval = interval
+	}
+}
+
+// SyncTime measures the offset between the service clock and the exchange
+// clock, assuming the exchange read its clock halfway through the round trip.
+func (ws *wonService) SyncTime(ctx context.Context) error {
+	sent := ws.clock()
+	serverTime, err := ws.TimeCtx(ctx)
+	if err != nil {
+		return err
+	}
+	received := ws.clock()
+	local := sent.Add(received.Sub(sent) / 2)
+	offset := serverTime.Sub(local)
+	atomic.StoreInt64(&ws.clockOffset, int64(offset))
+	level.Debug(ws.Logger).Log("clockOffset", offset, "roundTrip", received.Sub(sent))
+	return nil
+}
+
+// ClockOffset returns how far the exchange clock is ahead of the service
+// clock, as last measured by SyncTime.
+func (ws *wonService) ClockOffset() time.Duration {
+	return time.Duration(atomic.LoadInt64(&ws.clockOffset))
+}
+
+// now returns the service clock corrected by the measured offset.
+func (ws *wonService) now() time.Time {
+	return ws.clock().Add(ws.ClockOffset())
+}
+
+func (ws *wonService) timestamp() int64 {
+	return ws.now().UnixNano() / int64(time.Millisecond)
+}
+
+// Close stops the time sync loop, if any. The service stays usable.
+func (ws *wonService) Close() error {
+	if ws.stopTimeSync != nil {
+		ws.stopTimeSync()
+	}
+	return nil
+}
+
+func (ws *wonService) syncTimeLoop(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		if err := ws.SyncTime(ctx); err != nil && ctx.Err() == nil {
+			level.Warn(ws.Logger).Log("msg", "time sync failed", "err", err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,273 @@
+package pkg
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/go-kit/kit/log"
+	"github.com/go-kit/kit/log/level"
+)
+
+// OrderEventType is a step in the lifecycle of an order.
+type OrderEventType int
+
+const (
+	OrderAccepted OrderEventType = iota
+	OrderPartiallyFilled
+	OrderFilled
+	OrderCancelled
+	OrderRejected
+)
+
+func (t OrderEventType) String() string {
+	switch t {
+	case OrderAccepted:
+		return "accepted"
+	case OrderPartiallyFilled:
+		return "partially_filled"
+	case OrderFilled:
+		return "filled"
+	case OrderCancelled:
+		return "cancelled"
+	case OrderRejected:
+		return "rejected"
+	}
+	return "unknown"
+}
+
+// OrderEvent reports a change of a tracked order.
+type OrderEvent struct {
+	Type  OrderEventType
+	Order Order
+	// Delta is the volume executed since the previous event of the order.
+	Delta Decimal
+	// Trades are the executions of the order found in MyTrades since the
+	// previous fill event.
+	Trades []MyTrade
+	// Reconciled reports whether the executions found in MyTrades so far add
+	// up to the executed volume of the order.
+	Reconciled bool
+}
+
+// tracked is the last known state of an order followed by an OrderTracker.
+type tracked struct {
+	order    *Order
+	executed Decimal
+	traded   Decimal
+	trades   []MyTrade
+	seen     map[int64]bool
+	done     chan struct{}
+}
+
+// OrderTracker follows orders by polling GetOrder and MyTrades, or from the
+// orders pushed to Apply, and reports each step of their lifecycle.
+type OrderTracker struct {
+	Logger log.Logger
+
+	service  Service
+	interval time.Duration
+	onEvent  func(OrderEvent)
+
+	mu      sync.Mutex
+	orders  map[int64]*tracked
+	cursors map[string]int64
+}
+
+// NewOrderTracker returns a tracker polling every interval and passing
+// every event to onEvent, which must not block.
+func NewOrderTracker(service Service, interval time.Duration, onEvent func(OrderEvent)) *OrderTracker {
+	return &OrderTracker{
+		Logger:   log.NewNopLogger(),
+		service:  service,
+		interval: interval,
+		onEvent:  onEvent,
+		orders:   make(map[int64]*tracked),
+		cursors:  make(map[string]int64),
+	}
+}
+
+// Watch starts tracking the orders with ids.
+func (t *OrderTracker) Watch(ids ...int64) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for _, id := range ids {
+		t.watch(id)
+	}
+}
+
+func (t *OrderTracker) watch(id int64) *tracked {
+	o, ok := t.orders[id]
+	if !ok {
+		o = &tracked{seen: make(map[int64]bool), done: make(chan struct{})}
+		t.orders[id] = o
+	}
+	return o
+}
+
+// Unwatch stops tracking the order with id. Waiters are not released.
+func (t *OrderTracker) Unwatch(id int64) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	delete(t.orders, id)
+}
+
+// Wait blocks until the order with id reaches a terminal state and returns
+// it, or until ctx is done. The order is watched if it was not.
+func (t *OrderTracker) Wait(ctx context.Context, id int64) (*Order, error) {
+	t.mu.Lock()
+	o := t.watch(id)
+	t.mu.Unlock()
+	select {
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case <-o.done:
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	order := *o.order
+	return &order, nil
+}
+
+// Run polls every interval until ctx is done. It always returns ctx.Err().
+func (t *OrderTracker) Run(ctx context.Context) error {
+	ticker := time.NewTicker(t.interval)
+	defer ticker.Stop()
+	for {
+		if err := t.Poll(ctx); err != nil && ctx.Err() == nil {
+			level.Warn(t.Logger).Log("msg", "order tracker poll failed", "err", err)
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+// Poll fetches every watched order that is not terminal yet and the new
+// executions of their markets, and emits what changed.
+func (t *OrderTracker) Poll(ctx context.Context) error {
+	t.mu.Lock()
+	var ids []int64
+	for id, o := range t.orders {
+		if o.order == nil || !o.order.State.IsTerminal() {
+			ids = append(ids, id)
+		}
+	}
+	t.mu.Unlock()
+
+	var orders []*Order
+	markets := make(map[string]bool)
+	for _, id := range ids {
+		order, err := t.service.GetOrderCtx(ctx, OrderRequest{Id: id})
+		if err != nil {
+			return err
+		}
+		orders = append(orders, order)
+		markets[order.Market] = true
+	}
+	for market := range markets {
+		if err := t.reconcile(ctx, market); err != nil {
+			return err
+		}
+	}
+	for _, order := range orders {
+		t.Apply(*order)
+	}
+	return nil
+}
+
+// reconcile records the executions of tracked orders in market. They are
+// reported with the next event of their order.
+func (t *OrderTracker) reconcile(ctx context.Context, market string) error {
+	t.mu.Lock()
+	last, ok := t.cursors[market]
+	t.mu.Unlock()
+	req := TradeRequest{Market: market}
+	if ok {
+		req.FromId = last + 1
+	}
+	trades, err := t.service.MyTradesCtx(ctx, req)
+	if err != nil {
+		return err
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for _, trade := range trades {
+		if trade.Id > t.cursors[market] {
+			t.cursors[market] = trade.Id
+		}
+		o, ok := t.orders[trade.OrderId]
+		if !ok || o.seen[trade.Id] {
+			continue
+		}
+		o.seen[trade.Id] = true
+		o.traded = o.traded.Add(trade.Quantity)
+		o.trades = append(o.trades, *trade)
+	}
+	return nil
+}
+
+// Apply records a new state of a watched order, such as one pushed by a
+// UserStream, and emits the events it implies. Unwatched orders are ignored.
+func (t *OrderTracker) Apply(order Order) {
+	t.mu.Lock()
+	o, ok := t.orders[order.Id]
+	if !ok || (o.order != nil && o.order.State.IsTerminal()) {
+		t.mu.Unlock()
+		return
+	}
+	first := o.order == nil
+	executed := order.Volume.Sub(order.RemainingVolume)
+	delta := executed.Sub(o.executed)
+	if !first && delta.Sign() <= 0 && order.State == o.order.State {
+		t.mu.Unlock()
+		return
+	}
+	current := order
+	o.order = &current
+	if delta.Sign() > 0 {
+		o.executed = executed
+	} else {
+		delta = Decimal{}
+	}
+
+	var events []OrderEvent
+	event := func(typ OrderEventType, delta Decimal) {
+		e := OrderEvent{Type: typ, Order: order, Delta: delta, Reconciled: o.traded.Equal(o.executed)}
+		if delta.Sign() > 0 {
+			e.Trades, o.trades = o.trades, nil
+		}
+		events = append(events, e)
+	}
+	if first && order.State != StateReject {
+		event(OrderAccepted, Decimal{})
+	}
+	switch order.State {
+	case StateDone:
+		event(OrderFilled, delta)
+	case StateCancel:
+		if delta.Sign() > 0 {
+			event(OrderPartiallyFilled, delta)
+		}
+		event(OrderCancelled, Decimal{})
+	case StateReject:
+		event(OrderRejected, Decimal{})
+	default:
+		if delta.Sign() > 0 {
+			event(OrderPartiallyFilled, delta)
+		}
+	}
+	if order.State.IsTerminal() {
+		close(o.done)
+	}
+	t.mu.Unlock()
+
+	if t.onEvent != nil {
+		for _, e := range events {
+			t.onEvent(e)
+		}
+	}
+}
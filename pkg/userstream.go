@@ -0,0 +1,395 @@
+package pkg
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/go-kit/kit/log"
+	"github.com/go-kit/kit/log/level"
+	"github.com/gorilla/websocket"
+)
+
+// Private streams pushed once a UserStream is logged in.
+const (
+	ordersStream   = "orders"
+	tradesStream   = "trades"
+	balancesStream = "balances"
+)
+
+type MyTradeEvent struct {
+	Market string
+	MyTrade
+}
+
+// UserHandler receives the events of a UserStream or a UserPoller. Nil
+// callbacks are skipped. Callbacks must not block.
+type UserHandler struct {
+	// OnOrder is called whenever an order changes state or is executed.
+	OnOrder func(Order)
+	// OnTrade is called for every execution of one of the account's orders.
+	OnTrade func(MyTradeEvent)
+	// OnBalance is called whenever the balance of a currency changes.
+	OnBalance func(CurrencyAccount)
+	// OnDisconnect is called with the cause whenever the stream connection
+	// is lost, before reconnecting.
+	OnDisconnect func(error)
+}
+
+// UserStream pushes the order, execution and balance updates of the account
+// over an authenticated websocket connection.
+type UserStream struct {
+	apiKey  string
+	signer  Signer
+	handler UserHandler
+	stream  *stream
+
+	mu       sync.Mutex
+	ctx      context.Context
+	fallback *UserPoller
+	stop     context.CancelFunc
+}
+
+// NewUserStream returns a stream reading from the websocket endpoint at url,
+// logging in with apiKey and signer.
+func NewUserStream(url string, apiKey string, signer Signer, handler UserHandler, opts ...StreamOption) *UserStream {
+	us := &UserStream{
+		apiKey:  apiKey,
+		signer:  signer,
+		handler: handler,
+	}
+	us.stream = newStream(url, opts)
+	us.stream.onConnect = us.login
+	us.stream.onMessage = us.dispatch
+	us.stream.onDisconnect = us.disconnected
+	return us
+}
+
+// SetFallback makes the stream run poller whenever it is disconnected, so
+// that updates keep flowing through the same handler over REST. The poller
+// is also polled once on every login and kept current with the events the
+// stream delivers, so that it emits exactly what the stream missed.
+func (us *UserStream) SetFallback(poller *UserPoller) {
+	us.mu.Lock()
+	defer us.mu.Unlock()
+	us.fallback = poller
+}
+
+func (us *UserStream) poller() *UserPoller {
+	us.mu.Lock()
+	defer us.mu.Unlock()
+	return us.fallback
+}
+
+// Run connects and delivers events until ctx is done, reconnecting whenever
+// the connection drops. It always returns ctx.Err().
+func (us *UserStream) Run(ctx context.Context) error {
+	us.mu.Lock()
+	us.ctx = ctx
+	us.mu.Unlock()
+	defer us.stopFallback()
+	return us.stream.run(ctx)
+}
+
+// login authenticates a new connection. The signed payload is the encoded
+// query api_key=<key>&timestamp=<ms>, as for REST endpoints, stamped with the
+// stream clock.
+func (us *UserStream) login(conn *websocket.Conn) error {
+	if us.signer == nil {
+		return errors.New("user stream requires a signer")
+	}
+	timestamp := strconv.FormatInt(us.stream.clock().UnixNano()/int64(time.Millisecond), 10)
+	payload := url.Values{"api_key": {us.apiKey}, "timestamp": {timestamp}}.Encode()
+	req := streamRequest{Method: "login", Params: []string{us.apiKey, timestamp, us.signer.Sign([]byte(payload))}}
+	if err := us.stream.sendOn(conn, req); err != nil {
+		return err
+	}
+
+	var ack struct {
+		Result string `json:"result"`
+		WonError
+	}
+	conn.SetReadDeadline(time.Now().Add(us.stream.readTimeout))
+	if err := conn.ReadJSON(&ack); err != nil {
+		return errors.New(fmt.Sprintf("user stream login failed:%s", err.Error()))
+	}
+	if ack.Code != "" {
+		ack.WonError.Path = "login"
+		return &ack.WonError
+	}
+	us.stopFallback()
+	us.catchUp()
+	return nil
+}
+
+// catchUp polls the fallback once, emitting what changed while the stream
+// was down, or recording the baseline the fallback diffs against the first
+// time.
+func (us *UserStream) catchUp() {
+	p := us.poller()
+	if p == nil {
+		return
+	}
+	us.mu.Lock()
+	ctx := us.ctx
+	us.mu.Unlock()
+	if err := p.Poll(ctx); err != nil && ctx.Err() == nil {
+		level.Warn(p.Logger).Log("msg", "user poll after login failed", "err", err)
+	}
+}
+
+func (us *UserStream) disconnected(err error) {
+	us.startFallback()
+	if us.handler.OnDisconnect != nil {
+		us.handler.OnDisconnect(err)
+	}
+}
+
+func (us *UserStream) startFallback() {
+	us.mu.Lock()
+	defer us.mu.Unlock()
+	if us.fallback == nil || us.stop != nil || us.ctx == nil {
+		return
+	}
+	ctx, stop := context.WithCancel(us.ctx)
+	us.stop = stop
+	go us.fallback.Run(ctx)
+}
+
+func (us *UserStream) stopFallback() {
+	us.mu.Lock()
+	defer us.mu.Unlock()
+	if us.stop != nil {
+		us.stop()
+		us.stop = nil
+	}
+}
+
+func (us *UserStream) dispatch(message []byte) error {
+	var msg streamMessage
+	if err := json.Unmarshal(message, &msg); err != nil {
+		return errors.New(fmt.Sprintf("stream message unmarshal failed:%s", err.Error()))
+	}
+
+	switch msg.Stream {
+	case "":
+		return nil
+	case ordersStream:
+		var order Order
+		if err := json.Unmarshal(msg.Data, &order); err != nil {
+			return errors.New(fmt.Sprintf("order event unmarshal failed:%s", err.Error()))
+		}
+		if p := us.poller(); p != nil {
+			p.observeOrder(order)
+		}
+		if us.handler.OnOrder != nil {
+			us.handler.OnOrder(order)
+		}
+	case tradesStream:
+		var raw struct {
+			Market string `json:"market"`
+			rawMyTrade
+		}
+		if err := json.Unmarshal(msg.Data, &raw); err != nil {
+			return errors.New(fmt.Sprintf("trade event unmarshal failed:%s", err.Error()))
+		}
+		event := MyTradeEvent{Market: raw.Market, MyTrade: raw.myTrade()}
+		if p := us.poller(); p != nil {
+			p.observeTrade(event)
+		}
+		if us.handler.OnTrade != nil {
+			us.handler.OnTrade(event)
+		}
+	case balancesStream:
+		var raw rawCurrencyAccount
+		if err := json.Unmarshal(msg.Data, &raw); err != nil {
+			return errors.New(fmt.Sprintf("balance event unmarshal failed:%s", err.Error()))
+		}
+		account := raw.currencyAccount()
+		if p := us.poller(); p != nil {
+			p.observeBalance(account)
+		}
+		if us.handler.OnBalance != nil {
+			us.handler.OnBalance(account)
+		}
+	default:
+		return errors.New(fmt.Sprintf("unknown stream:%s", msg.Stream))
+	}
+	return nil
+}
+
+// UserPoller emits the events of a UserStream by polling the REST API. The
+// first poll records a baseline and emits nothing; later polls emit the
+// differences with the previous one, or with the events observed by the
+// stream it is the fallback of.
+type UserPoller struct {
+	Logger log.Logger
+
+	service  Service
+	markets  []string
+	interval time.Duration
+	handler  UserHandler
+
+	mu       sync.Mutex
+	seeded   bool
+	orders   map[int64]Order
+	trades   map[string]int64
+	balances map[string]CurrencyAccount
+}
+
+// NewUserPoller returns a poller following the orders and trades of markets
+// and the account balances every interval.
+func NewUserPoller(service Service, markets []string, interval time.Duration, handler UserHandler) *UserPoller {
+	return &UserPoller{
+		Logger:   log.NewNopLogger(),
+		service:  service,
+		markets:  markets,
+		interval: interval,
+		handler:  handler,
+		orders:   make(map[int64]Order),
+		trades:   make(map[string]int64),
+		balances: make(map[string]CurrencyAccount),
+	}
+}
+
+// Run polls every interval until ctx is done. It always returns ctx.Err().
+func (p *UserPoller) Run(ctx context.Context) error {
+	ticker := time.NewTicker(p.interval)
+	defer ticker.Stop()
+	for {
+		if err := p.Poll(ctx); err != nil && ctx.Err() == nil {
+			level.Warn(p.Logger).Log("msg", "user poll failed", "err", err)
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+// Poll fetches the current state once and emits what changed since the
+// previous poll.
+func (p *UserPoller) Poll(ctx context.Context) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	emit := p.seeded
+
+	open := make(map[int64]bool)
+	for _, market := range p.markets {
+		orders, err := p.service.GetOrdersCtx(ctx, OrdersRequest{Market: market, State: StateWait})
+		if err != nil {
+			return err
+		}
+		for _, o := range orders {
+			open[o.Id] = true
+			p.updateOrder(*o, emit)
+		}
+	}
+	// Orders that left the open list reached a terminal state.
+	for id := range p.orders {
+		if open[id] {
+			continue
+		}
+		order, err := p.service.GetOrderCtx(ctx, OrderRequest{Id: id})
+		if err != nil {
+			return err
+		}
+		p.updateOrder(*order, emit)
+		delete(p.orders, id)
+	}
+
+	for _, market := range p.markets {
+		last, ok := p.trades[market]
+		req := TradeRequest{Market: market}
+		if ok {
+			req.FromId = last + 1
+		}
+		trades, err := p.service.MyTradesCtx(ctx, req)
+		if err != nil {
+			return err
+		}
+		for _, t := range trades {
+			if ok && t.Id <= last {
+				continue
+			}
+			if last, ok := p.trades[market]; !ok || t.Id > last {
+				p.trades[market] = t.Id
+			}
+			if emit && p.handler.OnTrade != nil {
+				p.handler.OnTrade(MyTradeEvent{Market: market, MyTrade: *t})
+			}
+		}
+	}
+
+	account, err := p.service.AccountCtx(ctx, AccountRequest{})
+	if err != nil {
+		return err
+	}
+	for _, a := range account.Accounts {
+		if prev, ok := p.balances[a.Currency]; ok && prev == a {
+			continue
+		}
+		p.balances[a.Currency] = a
+		if emit && p.handler.OnBalance != nil {
+			p.handler.OnBalance(a)
+		}
+	}
+
+	p.seeded = true
+	return nil
+}
+
+func (p *UserPoller) follows(market string) bool {
+	for _, m := range p.markets {
+		if m == market {
+			return true
+		}
+	}
+	return false
+}
+
+// observeOrder records an order event delivered by the stream.
+func (p *UserPoller) observeOrder(o Order) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if !p.follows(o.Market) {
+		return
+	}
+	if o.State.IsTerminal() {
+		delete(p.orders, o.Id)
+	} else {
+		p.orders[o.Id] = o
+	}
+}
+
+// observeTrade records a trade event delivered by the stream.
+func (p *UserPoller) observeTrade(e MyTradeEvent) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if last, ok := p.trades[e.Market]; p.follows(e.Market) && (!ok || e.Id > last) {
+		p.trades[e.Market] = e.Id
+	}
+}
+
+// observeBalance records a balance event delivered by the stream.
+func (p *UserPoller) observeBalance(a CurrencyAccount) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.balances[a.Currency] = a
+}
+
+func (p *UserPoller) updateOrder(o Order, emit bool) {
+	if prev, ok := p.orders[o.Id]; ok && prev == o {
+		return
+	}
+	p.orders[o.Id] = o
+	if emit && p.handler.OnOrder != nil {
+		p.handler.OnOrder(o)
+	}
+}
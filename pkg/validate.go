@@ -0,0 +1,182 @@
+package pkg
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+// ErrInvalidOrder is matched by every *ValidationError.
+var ErrInvalidOrder = errors.New("invalid order")
+
+// ValidationError reports an order rejected by an OrderValidator before it
+// was sent. It matches ErrInvalidOrder and, when set, Cause.
+type ValidationError struct {
+	Field  string
+	Reason string
+	// Cause is ErrUnknownMarket or ErrInsufficientFunds for the matching
+	// failures, nil otherwise.
+	Cause error
+}
+
+func (e *ValidationError) Error() string {
+	return fmt.Sprintf("invalid order %s:%s", e.Field, e.Reason)
+}
+
+func (e *ValidationError) Is(target error) bool {
+	return target == ErrInvalidOrder || (e.Cause != nil && target == e.Cause)
+}
+
+func invalid(field, format string, args ...interface{}) *ValidationError {
+	return &ValidationError{Field: field, Reason: fmt.Sprintf(format, args...)}
+}
+
+// ValidationConfig configures an OrderValidator.
+type ValidationConfig struct {
+	// PriceRounding and VolumeRounding select how the price is rounded to
+	// the market tick and the volume to its step. Both default to RoundDown.
+	PriceRounding  RoundingMode
+	VolumeRounding RoundingMode
+	// CheckBalance makes the validator fetch the account and reject orders
+	// the available balance cannot cover.
+	CheckBalance bool
+}
+
+// WithOrderValidation makes CreateOrder validate and normalize every order
+// with an OrderValidator before sending it.
+func WithOrderValidation(cfg ValidationConfig) Option {
+	return func(ws *wonService) {
+		ws.validator = NewOrderValidator(ws, cfg)
+	}
+}
+
+// OrderValidator checks orders against the market rules published by
+// ExchangeInfo.
+type OrderValidator struct {
+	service Service
+	cfg     ValidationConfig
+}
+
+func NewOrderValidator(service Service, cfg ValidationConfig) *OrderValidator {
+	return &OrderValidator{service: service, cfg: cfg}
+}
+
+// Validate returns cor with its price rounded to the market tick and its
+// volume to the market step, or a *ValidationError when the order breaks a
+// market rule.
+func (v *OrderValidator) Validate(ctx context.Context, cor CreateOrderRequest) (CreateOrderRequest, error) {
+	return v.validate(ctx, cor, &balances{})
+}
+
+// ValidateAll validates orders as Validate does, against a single fetch of
+// the account: every accepted order reserves its cost, so that the orders
+// must fit the available balance together. It returns the normalized orders
+// and the error of each.
+func (v *OrderValidator) ValidateAll(ctx context.Context, orders []CreateOrderRequest) ([]CreateOrderRequest, []error) {
+	b := &balances{}
+	validated := make([]CreateOrderRequest, len(orders))
+	errs := make([]error, len(orders))
+	for i, cor := range orders {
+		validated[i], errs[i] = v.validate(ctx, cor, b)
+	}
+	return validated, errs
+}
+
+func (v *OrderValidator) validate(ctx context.Context, cor CreateOrderRequest, b *balances) (CreateOrderRequest, error) {
+	if err := cor.Check(); err != nil {
+		return cor, err
+	}
+	market, err := v.service.MarketInfoCtx(ctx, cor.Market)
+	if err == ErrUnknownMarket {
+		return cor, &ValidationError{Field: "market", Reason: fmt.Sprintf("unknown market %q", cor.Market), Cause: err}
+	}
+	if err != nil {
+		return cor, err
+	}
+	if !market.Trading() {
+		return cor, invalid("market", "%s is %s", cor.Market, market.Status)
+	}
+
+	if cor.OrdType.HasPrice() {
+		cor.Price = cor.Price.RoundToStep(market.PriceTick, v.cfg.PriceRounding)
+		if cor.Price.Sign() <= 0 {
+			return cor, invalid("price", "must be positive after rounding to tick %s", market.PriceTick)
+		}
+	}
+	if cor.OrdType.IsStop() {
+		cor.StopPrice = cor.StopPrice.RoundToStep(market.PriceTick, v.cfg.PriceRounding)
+		if cor.StopPrice.Sign() <= 0 {
+			return cor, invalid("stop_price", "must be positive after rounding to tick %s", market.PriceTick)
+		}
+	}
+
+	// Market orders by funds have no volume to check; their funds are the
+	// notional.
+	notional := cor.Funds
+	if cor.Funds.IsZero() {
+		cor.Volume = cor.Volume.RoundToStep(market.VolumeStep, v.cfg.VolumeRounding)
+		if cor.Volume.Sign() <= 0 {
+			return cor, invalid("volume", "must be positive after rounding to step %s", market.VolumeStep)
+		}
+		if cor.Volume.Cmp(market.MinVolume) < 0 {
+			return cor, invalid("volume", "%s below minimum %s", cor.Volume, market.MinVolume)
+		}
+		if !market.MaxVolume.IsZero() && cor.Volume.Cmp(market.MaxVolume) > 0 {
+			return cor, invalid("volume", "%s above maximum %s", cor.Volume, market.MaxVolume)
+		}
+		notional = cor.Price.Mul(cor.Volume)
+	}
+	if (cor.OrdType.HasPrice() || !cor.Funds.IsZero()) && notional.Cmp(market.MinNotional) < 0 {
+		return cor, invalid("volume", "notional %s below minimum %s", notional, market.MinNotional)
+	}
+
+	if v.cfg.CheckBalance {
+		if err := v.checkBalance(ctx, b, cor, market, notional); err != nil {
+			return cor, err
+		}
+	}
+	return cor, nil
+}
+
+// balances holds the available balance of each currency, fetched on first
+// use and reduced by the cost of every order accepted against it.
+type balances struct {
+	fetched   bool
+	err       error
+	available map[string]Decimal
+}
+
+// checkBalance compares the order with the available balance of the currency
+// it spends, and reserves its cost. Orders whose cost is unknown before they
+// match, market buys by volume and market sells by funds, are not checked.
+func (v *OrderValidator) checkBalance(ctx context.Context, b *balances, cor CreateOrderRequest, market *MarketInfo, notional Decimal) error {
+	currency, need := market.BaseCurrency, cor.Volume
+	if cor.Side == SideBuy {
+		currency, need = market.QuoteCurrency, notional
+	}
+	if need.IsZero() {
+		return nil
+	}
+	if !b.fetched {
+		b.fetched = true
+		account, err := v.service.AccountCtx(ctx, AccountRequest{})
+		if err != nil {
+			b.err = err
+		} else {
+			b.available = make(map[string]Decimal, len(account.Accounts))
+			for _, a := range account.Accounts {
+				b.available[a.Currency] = a.Balance
+			}
+		}
+	}
+	if b.err != nil {
+		return b.err
+	}
+	available := b.available[currency]
+	if available.Cmp(need) < 0 {
+		return &ValidationError{Field: "volume", Reason: fmt.Sprintf("needs %s %s, %s available", need, currency, available),
+			Cause: ErrInsufficientFunds}
+	}
+	b.available[currency] = available.Sub(need)
+	return nil
+}
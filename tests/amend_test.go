@@ -0,0 +1,60 @@
+package tests
+
+import (
+	"testing"
+
+	"github.com/bmizerany/assert"
+	"github.com/xiangxian/exchange/pkg"
+)
+
+func amendService(url string, opts ...pkg.Option) pkg.Service {
+	opts = append(opts, pkg.WithSigner(&pkg.HmacSigner{Key: []byte("your secret key")}), pkg.WithRetryPolicy(fastRetry))
+	return pkg.NewService(url, opts...)
+}
+
+func amendRequest(id int64) pkg.AmendOrderRequest {
+	return pkg.AmendOrderRequest{Id: id, Order: pkg.LimitOrder("wonbtc", pkg.SideBuy, pkg.MustDecimal("0.2"), pkg.MustDecimal("5"))}
+}
+
+func TestAmendOrderCancelThenCreate(t *testing.T) {
+	book, server := newFakeBook(openOrder(1, "wonbtc", pkg.SideBuy))
+	defer server.Close()
+
+	// The replace endpoint is missing, so the service falls back.
+	result, err := amendService(server.URL, pkg.WithAmendEndpoint()).AmendOrder(amendRequest(1))
+	assert.Equal(t, nil, err)
+	assert.Equal(t, pkg.StateCancel, result.Old.State)
+	assert.Equal(t, int64(101), result.New.Id)
+	assert.Equal(t, "0.2", result.New.Price.String())
+	assert.Equal(t, 1, book.creates)
+}
+
+func TestAmendOrderAtomicReplace(t *testing.T) {
+	book, server := newFakeBook(openOrder(1, "wonbtc", pkg.SideBuy))
+	defer server.Close()
+	book.replace = true
+
+	result, err := amendService(server.URL, pkg.WithAmendEndpoint()).AmendOrder(amendRequest(1))
+	assert.Equal(t, nil, err)
+	assert.Equal(t, int64(1), result.Old.Id)
+	assert.Equal(t, int64(101), result.New.Id)
+	assert.Equal(t, pkg.StateCancel, book.orders[1].State)
+}
+
+func TestAmendOrderNotReplacedUnlessCancelled(t *testing.T) {
+	book, server := newFakeBook(openOrder(1, "wonbtc", pkg.SideBuy), openOrder(2, "wonbtc", pkg.SideBuy))
+	defer server.Close()
+	book.fillOnCancel[1] = true
+	book.stuck[2] = true
+	service := amendService(server.URL)
+
+	result, err := service.AmendOrder(amendRequest(1))
+	assert.Equal(t, pkg.ErrOrderFilled, err)
+	assert.Equal(t, pkg.StateDone, result.Old.State)
+	assert.Equal(t, (*pkg.Order)(nil), result.New)
+
+	result, err = service.AmendOrder(amendRequest(2))
+	assert.NotEqual(t, nil, err)
+	assert.Equal(t, pkg.StateWait, result.Old.State)
+	assert.Equal(t, 0, book.creates)
+}
@@ -0,0 +1,145 @@
+package tests
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/bmizerany/assert"
+	"github.com/xiangxian/exchange/pkg"
+)
+
+// newBatchServer places single orders with the id given as their price and
+// tracks the highest number of concurrent calls. With batch set it also
+// serves the batch endpoints, refusing order 2; otherwise they answer 404.
+func newBatchServer(batch bool) (*httptest.Server, *int32, func() []string) {
+	var mu sync.Mutex
+	var paths []string
+	var inFlight, maxInFlight int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		paths = append(paths, r.URL.Path)
+		mu.Unlock()
+		n := atomic.AddInt32(&inFlight, 1)
+		defer atomic.AddInt32(&inFlight, -1)
+		for {
+			max := atomic.LoadInt32(&maxInFlight)
+			if n <= max || atomic.CompareAndSwapInt32(&maxInFlight, max, n) {
+				break
+			}
+		}
+		time.Sleep(5 * time.Millisecond)
+
+		q := r.URL.Query()
+		switch r.URL.Path {
+		case "/api/v1/order/create":
+			fmt.Fprintf(w, `{"data":{"id":%s}}`, q.Get("price"))
+		case "/api/v1/order/cancel":
+			w.Write([]byte(`{"data":"success"}`))
+		case "/api/v1/orders/create_batch", "/api/v1/orders/cancel_batch":
+			if !batch {
+				w.WriteHeader(http.StatusNotFound)
+				return
+			}
+			var items []string
+			if r.URL.Path == "/api/v1/orders/create_batch" {
+				var orders []map[string]string
+				json.Unmarshal([]byte(q.Get("orders")), &orders)
+				for _, o := range orders {
+					items = append(items, fmt.Sprintf(`{"id":%s}`, o["price"]))
+				}
+			} else {
+				for _, id := range strings.Split(q.Get("ids"), ",") {
+					items = append(items, fmt.Sprintf(`{"id":%s,"result":"success"}`, id))
+				}
+			}
+			for i, item := range items {
+				if strings.HasPrefix(item, `{"id":2`) {
+					items[i] = `{"error":"insufficient_funds","error_description":"not enough btc"}`
+				}
+			}
+			fmt.Fprintf(w, `{"data":[%s]}`, strings.Join(items, ","))
+		}
+	}))
+	return server, &maxInFlight, func() []string {
+		mu.Lock()
+		defer mu.Unlock()
+		return append([]string(nil), paths...)
+	}
+}
+
+func gridOrders(n int) []pkg.CreateOrderRequest {
+	var orders []pkg.CreateOrderRequest
+	for i := 1; i <= n; i++ {
+		orders = append(orders, pkg.LimitOrder("wonbtc", pkg.SideBuy, pkg.NewDecimalFromInt(int64(i)), pkg.MustDecimal("1")))
+	}
+	return orders
+}
+
+func TestCreateOrdersFanOut(t *testing.T) {
+	server, maxInFlight, _ := newBatchServer(false)
+	defer server.Close()
+
+	service := pkg.NewService(server.URL,
+		pkg.WithSigner(&pkg.HmacSigner{Key: []byte("your secret key")}),
+		pkg.WithBatchConcurrency(3))
+	orders := gridOrders(10)
+	orders[4].Side = "short"
+	results := service.CreateOrders(orders)
+	assert.Equal(t, 10, len(results))
+	for i, r := range results {
+		if i == 4 {
+			assert.Equal(t, true, r.Err != nil)
+			continue
+		}
+		assert.Equal(t, nil, r.Err)
+		assert.Equal(t, int64(i+1), r.Order.Id)
+	}
+	assert.Equal(t, true, atomic.LoadInt32(maxInFlight) <= 3)
+	assert.Equal(t, true, atomic.LoadInt32(maxInFlight) > 1)
+}
+
+func TestCreateOrdersBatch(t *testing.T) {
+	server, _, paths := newBatchServer(true)
+	defer server.Close()
+
+	service := pkg.NewService(server.URL,
+		pkg.WithSigner(&pkg.HmacSigner{Key: []byte("your secret key")}),
+		pkg.WithBatchEndpoints(2))
+	results := service.CreateOrders(gridOrders(3))
+	assert.Equal(t, int64(1), results[0].Order.Id)
+	assert.Equal(t, true, pkg.IsInsufficientFunds(results[1].Err))
+	assert.Equal(t, int64(3), results[2].Order.Id)
+	assert.Equal(t, 2, len(paths()))
+
+	cancels := service.CancelOrders([]int64{1, 2, 3})
+	assert.Equal(t, nil, cancels[0].Err)
+	assert.Equal(t, true, pkg.IsInsufficientFunds(cancels[1].Err))
+	assert.Equal(t, int64(3), cancels[2].Id)
+	assert.Equal(t, nil, cancels[2].Err)
+}
+
+func TestBatchFallsBackToSingleCalls(t *testing.T) {
+	server, _, paths := newBatchServer(false)
+	defer server.Close()
+
+	service := pkg.NewService(server.URL,
+		pkg.WithSigner(&pkg.HmacSigner{Key: []byte("your secret key")}),
+		pkg.WithBatchEndpoints(10))
+	results := service.CreateOrders(gridOrders(3))
+	for i, r := range results {
+		assert.Equal(t, nil, r.Err)
+		assert.Equal(t, int64(i+1), r.Order.Id)
+	}
+	// One refused batch call, then single calls only.
+	assert.Equal(t, 4, len(paths()))
+	cancels := service.CancelOrders([]int64{1, 2})
+	assert.Equal(t, nil, cancels[1].Err)
+	assert.Equal(t, 6, len(paths()))
+}
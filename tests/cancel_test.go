@@ -0,0 +1,198 @@
+package tests
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sort"
+	"strconv"
+	"sync"
+	"testing"
+
+	"github.com/bmizerany/assert"
+	"github.com/xiangxian/exchange/pkg"
+)
+
+// fakeBook is an in-memory order table behind the order and trade
+// endpoints. Listings return at most two orders, to exercise repeated
+// listings. Orders in fillOnCancel fill just before their cancellation
+// arrives; orders in stuck cannot be cancelled. The replace endpoint answers
+// 404 unless replace is set.
+type fakeBook struct {
+	mu           sync.Mutex
+	orders       map[int64]*pkg.Order
+	trades       []map[string]interface{}
+	fillOnCancel map[int64]bool
+	stuck        map[int64]bool
+	replace      bool
+	creates      int
+}
+
+func newFakeBook(orders ...pkg.Order) (*fakeBook, *httptest.Server) {
+	fb := &fakeBook{orders: make(map[int64]*pkg.Order), fillOnCancel: make(map[int64]bool), stuck: make(map[int64]bool)}
+	for i := range orders {
+		fb.orders[orders[i].Id] = &orders[i]
+	}
+	return fb, httptest.NewServer(fb)
+}
+
+func (fb *fakeBook) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	fb.mu.Lock()
+	defer fb.mu.Unlock()
+	q := r.URL.Query()
+	id, _ := strconv.ParseInt(q.Get("id"), 10, 64)
+	reply := func(v interface{}) { json.NewEncoder(w).Encode(map[string]interface{}{"data": v}) }
+	switch r.URL.Path {
+	case "/api/v1/orders":
+		var list []*pkg.Order
+		for _, o := range fb.orders {
+			if (q.Get("market") == "" || o.Market == q.Get("market")) &&
+				(q.Get("side") == "" || string(o.Side) == q.Get("side")) &&
+				(q.Get("state") == "" || string(o.State) == q.Get("state")) {
+				list = append(list, o)
+			}
+		}
+		sort.Slice(list, func(i, j int) bool { return list[i].Id < list[j].Id })
+		if len(list) > 2 {
+			list = list[:2]
+		}
+		reply(list)
+	case "/api/v1/order":
+		o, ok := fb.orders[id]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			w.Write([]byte(`{"error":"order_not_found"}`))
+			return
+		}
+		reply(o)
+	case "/api/v1/order/create":
+		reply(fb.create(q))
+	case "/api/v1/order/replace":
+		if !fb.replace {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		old := fb.orders[id]
+		old.State = pkg.StateCancel
+		reply(map[string]interface{}{"old": old, "new": fb.create(q)})
+	case "/api/v1/trades/my":
+		from, _ := strconv.ParseInt(q.Get("from_id"), 10, 64)
+		var list []map[string]interface{}
+		for _, trade := range fb.trades {
+			if trade["id"].(int64) >= from {
+				list = append(list, trade)
+			}
+		}
+		reply(list)
+	case "/api/v1/order/cancel":
+		o := fb.orders[id]
+		if fb.fillOnCancel[id] {
+			o.State = pkg.StateDone
+		}
+		if fb.stuck[id] {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		if o.State != pkg.StateWait {
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(`{"error":"order_not_open"}`))
+			return
+		}
+		o.State = pkg.StateCancel
+		reply("success")
+	}
+}
+
+func (fb *fakeBook) create(q url.Values) *pkg.Order {
+	fb.creates++
+	id := int64(100 + fb.creates)
+	o := &pkg.Order{Id: id, Market: q.Get("market"), Side: pkg.Side(q.Get("side")), State: pkg.StateWait,
+		ClientOrderId: q.Get("client_order_id"), Price: pkg.MustDecimal(q.Get("price")),
+		Volume: pkg.MustDecimal(q.Get("volume")), RemainingVolume: pkg.MustDecimal(q.Get("volume"))}
+	fb.orders[id] = o
+	return o
+}
+
+// fill executes qty of order id through a new trade.
+func (fb *fakeBook) fill(id int64, qty string) {
+	fb.mu.Lock()
+	defer fb.mu.Unlock()
+	o := fb.orders[id]
+	o.RemainingVolume = o.RemainingVolume.Sub(pkg.MustDecimal(qty))
+	if o.RemainingVolume.IsZero() {
+		o.State = pkg.StateDone
+	}
+	fb.trades = append(fb.trades, map[string]interface{}{
+		"id": int64(len(fb.trades) + 1), "order_id": id, "qty": qty, "price": o.Price, "side": o.Side})
+}
+
+func openOrder(id int64, market string, side pkg.Side) pkg.Order {
+	return pkg.Order{Id: id, Market: market, Side: side, State: pkg.StateWait}
+}
+
+func reportIds(orders []*pkg.Order) []int64 {
+	var ids []int64
+	for _, o := range orders {
+		ids = append(ids, o.Id)
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+	return ids
+}
+
+func TestCancelAll(t *testing.T) {
+	book, server := newFakeBook(
+		openOrder(1, "wonbtc", pkg.SideBuy),
+		openOrder(2, "wonbtc", pkg.SideBuy),
+		openOrder(3, "wonbtc", pkg.SideSell),
+		openOrder(4, "wonbtc", pkg.SideBuy),
+		openOrder(5, "wonbtc", pkg.SideBuy),
+		openOrder(6, "woneth", pkg.SideBuy))
+	defer server.Close()
+	book.fillOnCancel[2] = true
+	book.stuck[5] = true
+
+	service := pkg.NewService(server.URL, pkg.WithSigner(&pkg.HmacSigner{Key: []byte("your secret key")}),
+		pkg.WithRetryPolicy(pkg.RetryPolicy{}))
+	report, err := service.CancelAll("wonbtc", pkg.SideBuy)
+	// Order 5 is still open after every round.
+	assert.Equal(t, pkg.ErrCancelIncomplete, err)
+	assert.Equal(t, []int64{1, 4}, reportIds(report.Cancelled))
+	assert.Equal(t, []int64{2}, reportIds(report.Filled))
+	assert.Equal(t, 1, len(report.Failed))
+	assert.Equal(t, int64(5), report.Failed[0].Order.Id)
+	assert.Equal(t, pkg.StateWait, book.orders[3].State)
+	assert.Equal(t, pkg.StateWait, book.orders[6].State)
+}
+
+func TestCancelAllPageOfFailures(t *testing.T) {
+	book, server := newFakeBook(
+		openOrder(1, "wonbtc", pkg.SideBuy),
+		openOrder(2, "wonbtc", pkg.SideBuy),
+		openOrder(3, "wonbtc", pkg.SideBuy))
+	defer server.Close()
+	book.stuck[1] = true
+	book.stuck[2] = true
+
+	// The listing only ever shows the two stuck orders, hiding order 3.
+	service := pkg.NewService(server.URL, pkg.WithSigner(&pkg.HmacSigner{Key: []byte("your secret key")}),
+		pkg.WithRetryPolicy(pkg.RetryPolicy{}))
+	report, err := service.CancelAll("wonbtc", "")
+	assert.Equal(t, pkg.ErrCancelIncomplete, err)
+	assert.Equal(t, 2, len(report.Failed))
+	assert.Equal(t, pkg.StateWait, book.orders[3].State)
+}
+
+func TestCancelWhere(t *testing.T) {
+	book, server := newFakeBook(
+		openOrder(1, "wonbtc", pkg.SideBuy),
+		openOrder(2, "woneth", pkg.SideSell),
+		openOrder(3, "woneth", pkg.SideBuy))
+	defer server.Close()
+
+	service := pkg.NewService(server.URL, pkg.WithSigner(&pkg.HmacSigner{Key: []byte("your secret key")}))
+	report, err := service.CancelWhere(func(o *pkg.Order) bool { return o.Market == "woneth" })
+	assert.Equal(t, nil, err)
+	assert.Equal(t, []int64{2, 3}, reportIds(report.Cancelled))
+	assert.Equal(t, pkg.StateWait, book.orders[1].State)
+}
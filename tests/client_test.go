@@ -0,0 +1,68 @@
+package tests
+
+import (
+	"crypto/tls"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+
+	"github.com/bmizerany/assert"
+	"github.com/xiangxian/exchange/pkg"
+)
+
+func newTickerServer() *httptest.Server {
+	return httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"data":{"market":"wonbtc","price":"0.00001"}}`))
+	}))
+}
+
+func TestConnectionReuse(t *testing.T) {
+	var conns int32
+	server := newTickerServer()
+	server.Config.ConnState = func(c net.Conn, s http.ConnState) {
+		if s == http.StateNew {
+			atomic.AddInt32(&conns, 1)
+		}
+	}
+	server.Start()
+	defer server.Close()
+
+	service := pkg.NewService(server.URL)
+	for i := 0; i < 10; i++ {
+		_, err := service.TickerPrice(pkg.TickerPriceRequest{Market: "wonbtc"})
+		assert.Equal(t, nil, err)
+	}
+	assert.Equal(t, int32(1), atomic.LoadInt32(&conns))
+}
+
+func benchmarkTickerPrice(b *testing.B, pooled bool) {
+	server := newTickerServer()
+	server.StartTLS()
+	defer server.Close()
+
+	tlsConfig := &tls.Config{InsecureSkipVerify: true}
+	var service pkg.Service
+	if pooled {
+		service = pkg.NewService(server.URL, pkg.WithTLSConfig(tlsConfig))
+	} else {
+		client := &http.Client{Transport: &http.Transport{TLSClientConfig: tlsConfig, DisableKeepAlives: true}}
+		service = pkg.NewService(server.URL, pkg.WithHTTPClient(client))
+	}
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := service.TickerPrice(pkg.TickerPriceRequest{Market: "wonbtc"}); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkTickerPricePooled(b *testing.B) {
+	benchmarkTickerPrice(b, true)
+}
+
+func BenchmarkTickerPriceNewConnection(b *testing.B) {
+	benchmarkTickerPrice(b, false)
+}
@@ -0,0 +1,166 @@
+package tests
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/bmizerany/assert"
+	"github.com/xiangxian/exchange/pkg"
+)
+
+// newLossyOrderServer answers the first create call with a 503. When landed
+// is set the order is placed anyway, as when a response is lost.
+func newLossyOrderServer(landed bool) (*httptest.Server, func() []url.Values) {
+	var mu sync.Mutex
+	var creates []url.Values
+	placed := ""
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		switch r.URL.Path {
+		case "/api/v1/order/create":
+			creates = append(creates, r.URL.Query())
+			if len(creates) == 1 {
+				if landed {
+					placed = r.URL.Query().Get("client_order_id")
+				}
+				w.WriteHeader(http.StatusServiceUnavailable)
+				return
+			}
+			fmt.Fprintf(w, `{"data":{"id":2,"client_order_id":%q}}`, r.URL.Query().Get("client_order_id"))
+		case "/api/v1/orders":
+			if placed == "" {
+				w.Write([]byte(`{"data":[{"id":7,"client_order_id":"other"}]}`))
+				return
+			}
+			fmt.Fprintf(w, `{"data":[{"id":1,"client_order_id":%q,"state":"wait"}]}`, placed)
+		}
+	}))
+	return server, func() []url.Values {
+		mu.Lock()
+		defer mu.Unlock()
+		return creates
+	}
+}
+
+func TestCreateOrRecoverFindsLandedOrder(t *testing.T) {
+	server, creates := newLossyOrderServer(true)
+	defer server.Close()
+
+	service := pkg.NewService(server.URL,
+		pkg.WithSigner(&pkg.HmacSigner{Key: []byte("your secret key")}),
+		pkg.WithRetryPolicy(fastRetry))
+	order, err := service.CreateOrRecoverOrder(pkg.LimitOrder("wonbtc", pkg.SideBuy, pkg.MustDecimal("1"), pkg.MustDecimal("1")))
+	assert.Equal(t, nil, err)
+	assert.Equal(t, int64(1), order.Id)
+	assert.Equal(t, 1, len(creates()))
+	assert.Equal(t, 32, len(order.ClientOrderId))
+}
+
+func TestCreateOrRecoverResendsLostOrder(t *testing.T) {
+	server, creates := newLossyOrderServer(false)
+	defer server.Close()
+
+	service := pkg.NewService(server.URL,
+		pkg.WithSigner(&pkg.HmacSigner{Key: []byte("your secret key")}),
+		pkg.WithRetryPolicy(fastRetry))
+	cor := pkg.LimitOrder("wonbtc", pkg.SideBuy, pkg.MustDecimal("1"), pkg.MustDecimal("1"))
+	cor.ClientOrderId = "my-order-1"
+	order, err := service.CreateOrRecoverOrder(cor)
+	assert.Equal(t, nil, err)
+	assert.Equal(t, int64(2), order.Id)
+	assert.Equal(t, 2, len(creates()))
+	assert.Equal(t, "my-order-1", creates()[0].Get("client_order_id"))
+	assert.Equal(t, "my-order-1", creates()[1].Get("client_order_id"))
+}
+
+// newHangUpServer places every order it is sent and then, instead of
+// answering, runs hangUp on the connection.
+func newHangUpServer(hangUp func(net.Conn)) (*httptest.Server, *int32, *int32) {
+	var creates, lookups int32
+	var placed atomic.Value
+	placed.Store("")
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/api/v1/order/create":
+			atomic.AddInt32(&creates, 1)
+			placed.Store(r.URL.Query().Get("client_order_id"))
+			conn, _, err := w.(http.Hijacker).Hijack()
+			if err != nil {
+				return
+			}
+			hangUp(conn)
+		case "/api/v1/orders":
+			atomic.AddInt32(&lookups, 1)
+			fmt.Fprintf(w, `{"data":[{"id":1,"client_order_id":%q,"state":"wait"}]}`, placed.Load())
+		}
+	}))
+	return server, &creates, &lookups
+}
+
+func TestCreateOrRecoverAfterDroppedConnection(t *testing.T) {
+	server, creates, lookups := newHangUpServer(func(conn net.Conn) { conn.Close() })
+	defer server.Close()
+
+	service := pkg.NewService(server.URL,
+		pkg.WithSigner(&pkg.HmacSigner{Key: []byte("your secret key")}),
+		pkg.WithRetryPolicy(fastRetry))
+	order, err := service.CreateOrRecoverOrder(pkg.LimitOrder("wonbtc", pkg.SideBuy, pkg.MustDecimal("1"), pkg.MustDecimal("1")))
+	assert.Equal(t, nil, err)
+	assert.Equal(t, int64(1), order.Id)
+	assert.Equal(t, int32(1), atomic.LoadInt32(creates))
+	assert.Equal(t, int32(1), atomic.LoadInt32(lookups))
+}
+
+func TestCreateOrRecoverAfterDeadline(t *testing.T) {
+	release := make(chan struct{})
+	server, creates, lookups := newHangUpServer(func(conn net.Conn) {
+		<-release
+		conn.Close()
+	})
+	defer server.Close()
+	defer close(release)
+
+	service := pkg.NewService(server.URL,
+		pkg.WithSigner(&pkg.HmacSigner{Key: []byte("your secret key")}),
+		pkg.WithRetryPolicy(fastRetry))
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	order, err := service.CreateOrRecoverOrderCtx(ctx, pkg.LimitOrder("wonbtc", pkg.SideBuy, pkg.MustDecimal("1"), pkg.MustDecimal("1")))
+	assert.Equal(t, nil, err)
+	assert.Equal(t, int64(1), order.Id)
+	assert.Equal(t, int32(1), atomic.LoadInt32(creates))
+	assert.Equal(t, int32(1), atomic.LoadInt32(lookups))
+}
+
+func TestCreateOrRecoverRejected(t *testing.T) {
+	server := newErrorServer(http.StatusBadRequest, `{"error":"insufficient_balance","error_description":"not enough btc"}`)
+	defer server.Close()
+
+	service := pkg.NewService(server.URL,
+		pkg.WithSigner(&pkg.HmacSigner{Key: []byte("your secret key")}),
+		pkg.WithRetryPolicy(fastRetry))
+	_, err := service.CreateOrRecoverOrder(pkg.LimitOrder("wonbtc", pkg.SideBuy, pkg.MustDecimal("1"), pkg.MustDecimal("1")))
+	assert.Equal(t, true, pkg.IsInsufficientFunds(err))
+}
+
+func TestGetOrderByClientOrderId(t *testing.T) {
+	server := newFlakyServer(0, 0, nil, `{"data":{"id":1495,"client_order_id":"my-order-1"}}`)
+	defer server.Close()
+
+	service := pkg.NewService(server.URL, pkg.WithSigner(&pkg.HmacSigner{Key: []byte("your secret key")}))
+	order, err := service.GetOrder(pkg.OrderRequest{ClientOrderId: "my-order-1"})
+	assert.Equal(t, nil, err)
+	assert.Equal(t, int64(1495), order.Id)
+	assert.Equal(t, "my-order-1", server.queries[0]["client_order_id"][0])
+	_, hasId := server.queries[0]["id"]
+	assert.Equal(t, false, hasId)
+}
@@ -0,0 +1,49 @@
+package tests
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/bmizerany/assert"
+	"github.com/xiangxian/exchange"
+	"github.com/xiangxian/exchange/pkg"
+)
+
+func TestDepthCtxDeadline(t *testing.T) {
+	release := make(chan struct{})
+	defer close(release)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-r.Context().Done():
+		case <-release:
+		}
+	}))
+	defer server.Close()
+
+	won := exchange.NewWon(pkg.NewService(server.URL))
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+	_, err := won.DepthCtx(ctx, pkg.DepthRequest{Market: "wonbtc", Limit: 10})
+	assert.NotEqual(t, nil, err)
+	assert.Equal(t, true, time.Since(start) < time.Second)
+}
+
+func TestCancelOrderCtxCanceled(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Errorf("request should not reach the server")
+	}))
+	defer server.Close()
+
+	signer := &pkg.HmacSigner{Key: []byte("your secret key")}
+	won := exchange.NewWon(pkg.NewService(server.URL, pkg.WithSigner(signer)))
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	err := won.CancelOrderCtx(ctx, pkg.CancelOrderRequest{Id: 1501, Timestamp: time.Now().Unix() * 1000})
+	assert.NotEqual(t, nil, err)
+}
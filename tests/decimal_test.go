@@ -0,0 +1,85 @@
+package tests
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/bmizerany/assert"
+	"github.com/xiangxian/exchange/pkg"
+)
+
+func TestDecimalParse(t *testing.T) {
+	for in, want := range map[string]string{
+		"0.00001": "0.00001",
+		"10":      "10",
+		"-1.50":   "-1.50",
+		"1.5e-8":  "0.000000015",
+		"2E3":     "2000",
+		".5":      "0.5",
+		"-.5":     "-0.5",
+		"+3.":     "3",
+	} {
+		d, err := pkg.NewDecimal(in)
+		assert.Equal(t, nil, err)
+		assert.Equal(t, want, d.String())
+	}
+	for _, in := range []string{"", "abc", "1.2.3", "1e", "--1", "1e1001", "1e-1001", "1e999999999"} {
+		_, err := pkg.NewDecimal(in)
+		assert.NotEqual(t, nil, err)
+	}
+	assert.Equal(t, 1001, len(pkg.MustDecimal("1e1000").String()))
+	assert.Equal(t, "0", pkg.Decimal{}.String())
+}
+
+func TestDecimalArithmetic(t *testing.T) {
+	a, b := pkg.MustDecimal("0.1"), pkg.MustDecimal("0.2")
+	assert.Equal(t, "0.3", a.Add(b).String())
+	assert.Equal(t, "-0.1", a.Sub(b).String())
+	assert.Equal(t, "0.02", a.Mul(b).String())
+	assert.Equal(t, "0.33333333", pkg.MustDecimal("1").Div(pkg.MustDecimal("3"), 8, pkg.RoundDown).String())
+	assert.Equal(t, true, pkg.MustDecimal("1.5").Equal(pkg.MustDecimal("1.500")))
+	assert.Equal(t, -1, a.Cmp(b))
+	assert.Equal(t, "1.5", pkg.MustDecimal("1.500").Canonical().String())
+}
+
+func TestDecimalRounding(t *testing.T) {
+	cases := []struct {
+		in   string
+		mode pkg.RoundingMode
+		want string
+	}{
+		{"2.345", pkg.RoundDown, "2.34"},
+		{"2.341", pkg.RoundUp, "2.35"},
+		{"2.345", pkg.RoundHalfUp, "2.35"},
+		{"2.345", pkg.RoundHalfEven, "2.34"},
+		{"2.355", pkg.RoundHalfEven, "2.36"},
+		{"-2.345", pkg.RoundHalfUp, "-2.35"},
+		{"-2.341", pkg.RoundDown, "-2.34"},
+	}
+	for _, c := range cases {
+		assert.Equal(t, c.want, pkg.MustDecimal(c.in).Round(2, c.mode).String())
+	}
+	assert.Equal(t, "1200", pkg.MustDecimal("1234.5678").Round(-2, pkg.RoundHalfUp).String())
+	assert.Equal(t, "1300", pkg.MustDecimal("1250").Round(-2, pkg.RoundHalfUp).String())
+	assert.Equal(t, "-1000", pkg.MustDecimal("-1234.5").Truncate(-3).String())
+	assert.Equal(t, "3300", pkg.MustDecimal("10000").Div(pkg.MustDecimal("3"), -2, pkg.RoundDown).String())
+	assert.Equal(t, "1.25", pkg.MustDecimal("1.27").RoundToStep(pkg.MustDecimal("0.05"), pkg.RoundDown).String())
+	assert.Equal(t, "1.30", pkg.MustDecimal("1.27").RoundToStep(pkg.MustDecimal("0.05"), pkg.RoundUp).String())
+}
+
+func TestDecimalJSON(t *testing.T) {
+	var v struct {
+		A pkg.Decimal `json:"a"`
+		B pkg.Decimal `json:"b"`
+		C pkg.Decimal `json:"c"`
+	}
+	assert.Equal(t, nil, json.Unmarshal([]byte(`{"a":"0.00000001","b":12.5,"c":null}`), &v))
+	assert.Equal(t, "0.00000001", v.A.String())
+	assert.Equal(t, "12.5", v.B.String())
+	assert.Equal(t, true, v.C.IsZero())
+
+	raw, err := json.Marshal(v)
+	assert.Equal(t, nil, err)
+	assert.Equal(t, `{"a":"0.00000001","b":"12.5","c":"0"}`, string(raw))
+	assert.NotEqual(t, nil, json.Unmarshal([]byte(`{"a":"1x"}`), &v))
+}
@@ -0,0 +1,40 @@
+package tests
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/bmizerany/assert"
+	"github.com/xiangxian/exchange/pkg"
+)
+
+func TestOrderEnumsJSON(t *testing.T) {
+	var order pkg.Order
+	assert.Equal(t, nil, json.Unmarshal([]byte(`{"side":"buy","ord_type":"market","state":"cancel"}`), &order))
+	assert.Equal(t, pkg.SideBuy, order.Side)
+	assert.Equal(t, pkg.OrdTypeMarket, order.OrdType)
+	assert.Equal(t, pkg.StateCancel, order.State)
+
+	raw, _ := json.Marshal(order)
+	var back pkg.Order
+	assert.Equal(t, nil, json.Unmarshal(raw, &back))
+	assert.Equal(t, order.Side, back.Side)
+	assert.Equal(t, order.OrdType, back.OrdType)
+	assert.Equal(t, order.State, back.State)
+
+	assert.NotEqual(t, nil, json.Unmarshal([]byte(`{"side":"long"}`), &order))
+	assert.NotEqual(t, nil, json.Unmarshal([]byte(`{"ord_type":"iceberg"}`), &order))
+	assert.NotEqual(t, nil, json.Unmarshal([]byte(`{"state":"pending"}`), &order))
+}
+
+func TestStateIsTerminal(t *testing.T) {
+	assert.Equal(t, false, pkg.StateWait.IsTerminal())
+	assert.Equal(t, true, pkg.StateDone.IsTerminal())
+	assert.Equal(t, true, pkg.StateCancel.IsTerminal())
+	assert.Equal(t, true, pkg.StateReject.IsTerminal())
+
+	_, err := pkg.ParseState("wait")
+	assert.Equal(t, nil, err)
+	_, err = pkg.ParseSide("short")
+	assert.NotEqual(t, nil, err)
+}
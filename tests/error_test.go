@@ -0,0 +1,120 @@
+package tests
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/bmizerany/assert"
+	"github.com/xiangxian/exchange/pkg"
+)
+
+func newErrorServer(status int, body string) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(status)
+		w.Write([]byte(body))
+	}))
+}
+
+func TestErrorOnEveryEndpoint(t *testing.T) {
+	server := newErrorServer(http.StatusBadRequest, `{"error":"invalid_market","error_description":"market not found"}`)
+	defer server.Close()
+
+	service := pkg.NewService(server.URL, pkg.WithSigner(&pkg.HmacSigner{Key: []byte("your secret key")}))
+	order := pkg.LimitOrder("nope", pkg.SideBuy, pkg.MustDecimal("1"), pkg.MustDecimal("1"))
+	calls := map[string]func() error{
+		"Time":         func() error { _, err := service.Time(); return err },
+		"Depth":        func() error { _, err := service.Depth(pkg.DepthRequest{Market: "nope"}); return err },
+		"RecentTrades": func() error { _, err := service.RecentTrades(pkg.TradeRequest{Market: "nope"}); return err },
+		"MyTrades":     func() error { _, err := service.MyTrades(pkg.TradeRequest{Market: "nope"}); return err },
+		"TickerPrice":  func() error { _, err := service.TickerPrice(pkg.TickerPriceRequest{Market: "nope"}); return err },
+		"Account":      func() error { _, err := service.Account(pkg.AccountRequest{}); return err },
+		"CreateOrder":  func() error { _, err := service.CreateOrder(order); return err },
+		"GetOrders":    func() error { _, err := service.GetOrders(pkg.OrdersRequest{Market: "nope"}); return err },
+		"GetOrder":     func() error { _, err := service.GetOrder(pkg.OrderRequest{Id: 1}); return err },
+		"CancelOrder":  func() error { return service.CancelOrder(pkg.CancelOrderRequest{Id: 1}) },
+	}
+	for name, call := range calls {
+		err := call()
+		wonErr, ok := err.(*pkg.WonError)
+		assert.Equal(t, true, ok, name)
+		if !ok {
+			continue
+		}
+		assert.Equal(t, http.StatusBadRequest, wonErr.StatusCode, name)
+		assert.Equal(t, "invalid_market", wonErr.Code, name)
+		assert.Equal(t, "market not found", wonErr.Message, name)
+		assert.NotEqual(t, "", wonErr.Path, name)
+	}
+}
+
+func TestErrorWithoutJSONBody(t *testing.T) {
+	server := newErrorServer(http.StatusBadGateway, "bad gateway\n")
+	defer server.Close()
+
+	_, err := pkg.NewService(server.URL).TickerPrice(pkg.TickerPriceRequest{Market: "wonbtc"})
+	wonErr, ok := err.(*pkg.WonError)
+	assert.Equal(t, true, ok)
+	assert.Equal(t, http.StatusBadGateway, wonErr.StatusCode)
+	assert.Equal(t, "bad gateway", wonErr.Message)
+}
+
+func TestErrorInSuccessEnvelope(t *testing.T) {
+	server := newErrorServer(http.StatusOK, `{"error":"invalid_signature","error_description":"signature mismatch"}`)
+	defer server.Close()
+
+	_, err := pkg.NewService(server.URL).Depth(pkg.DepthRequest{Market: "wonbtc"})
+	wonErr, ok := err.(*pkg.WonError)
+	assert.Equal(t, true, ok)
+	assert.Equal(t, "invalid_signature", wonErr.Code)
+}
+
+func TestMissingData(t *testing.T) {
+	for _, body := range []string{`{}`, `{"data":null}`} {
+		server := newErrorServer(http.StatusOK, body)
+		service := pkg.NewService(server.URL, pkg.WithSigner(&pkg.HmacSigner{Key: []byte("your secret key")}))
+
+		order, err := service.GetOrder(pkg.OrderRequest{Id: 1})
+		assert.NotEqual(t, nil, err, body)
+		assert.Equal(t, (*pkg.Order)(nil), order, body)
+		_, err = service.Depth(pkg.DepthRequest{Market: "wonbtc"})
+		assert.NotEqual(t, nil, err, body)
+
+		// An empty list may come without data.
+		orders, err := service.GetOrders(pkg.OrdersRequest{Market: "wonbtc"})
+		assert.Equal(t, nil, err, body)
+		assert.Equal(t, 0, len(orders), body)
+		server.Close()
+	}
+}
+
+func TestErrorCauses(t *testing.T) {
+	cases := []struct {
+		status int
+		body   string
+		is     func(error) bool
+		cause  error
+	}{
+		{http.StatusBadRequest, `{"error":"insufficient_balance","error_description":"not enough wonbtc"}`, pkg.IsInsufficientFunds, pkg.ErrInsufficientFunds},
+		{http.StatusUnauthorized, `{"error":"invalid_signature","error_description":"signature mismatch"}`, pkg.IsInvalidSignature, pkg.ErrInvalidSignature},
+		{http.StatusBadRequest, `{"error":"invalid_timestamp","error_description":"outside recv window"}`, pkg.IsTimestampOutOfWindow, pkg.ErrTimestampOutOfWindow},
+		{http.StatusTooManyRequests, `slow down`, pkg.IsRateLimited, pkg.ErrRateLimited},
+		{http.StatusNotFound, `{"error":"order_not_found","error_description":"order 1495 not found"}`, pkg.IsOrderNotFound, pkg.ErrOrderNotFound},
+	}
+	for _, c := range cases {
+		server := newErrorServer(c.status, c.body)
+		service := pkg.NewService(server.URL, pkg.WithSigner(&pkg.HmacSigner{Key: []byte("your secret key")}))
+		_, err := service.GetOrder(pkg.OrderRequest{Id: 1495})
+		server.Close()
+
+		assert.Equal(t, true, c.is(err), c.body)
+		assert.Equal(t, true, errors.Is(err, c.cause), c.body)
+		assert.Equal(t, false, pkg.IsInsufficientFunds(err) && c.cause != pkg.ErrInsufficientFunds, c.body)
+
+		var wonErr *pkg.WonError
+		assert.Equal(t, true, errors.As(err, &wonErr), c.body)
+		assert.Equal(t, "api/v1/order", wonErr.Path)
+		assert.Equal(t, c.body, string(wonErr.Body))
+	}
+}
@@ -0,0 +1,169 @@
+package tests
+
+import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/bmizerany/assert"
+	"github.com/xiangxian/exchange/pkg"
+)
+
+func TestExportTradesResumes(t *testing.T) {
+	server, _ := newHistoryServer()
+	defer server.Close()
+	service := pkg.NewService(server.URL, pkg.WithSigner(&pkg.HmacSigner{Key: []byte("your secret key")}))
+
+	var buf bytes.Buffer
+	w, err := pkg.NewRecordWriter(pkg.FormatCSV, &buf, pkg.TradeColumns, false)
+	assert.Equal(t, nil, err)
+	cp := &pkg.ExportCheckpoint{LastId: 3}
+	n, err := pkg.ExportTrades(context.Background(), service, "wonbtc", w, cp)
+	assert.Equal(t, nil, err)
+	assert.Equal(t, 4, n)
+	assert.Equal(t, int64(7), cp.LastId)
+
+	records, err := csv.NewReader(&buf).ReadAll()
+	assert.Equal(t, nil, err)
+	assert.Equal(t, pkg.TradeColumns, records[0])
+	assert.Equal(t, 5, len(records))
+	assert.Equal(t, "4", records[1][0])
+	assert.Equal(t, "wonbtc", records[1][2])
+
+	// Nothing new since the checkpoint.
+	n, err = pkg.ExportTrades(context.Background(), service, "wonbtc", w, cp)
+	assert.Equal(t, nil, err)
+	assert.Equal(t, 0, n)
+}
+
+func TestExportOrdersColumnar(t *testing.T) {
+	server, _ := newHistoryServer()
+	defer server.Close()
+	service := pkg.NewService(server.URL, pkg.WithSigner(&pkg.HmacSigner{Key: []byte("your secret key")}))
+
+	var buf bytes.Buffer
+	cp := &pkg.ExportCheckpoint{LastId: 3, LastStamp: 20}
+	w, err := pkg.NewRecordWriter(pkg.FormatColumnar, &buf, pkg.OrderColumns, false)
+	assert.Equal(t, nil, err)
+	n, err := pkg.ExportOrders(context.Background(), service, "", w, cp)
+	assert.Equal(t, nil, err)
+	assert.Equal(t, 4, n)
+	assert.Equal(t, pkg.ExportCheckpoint{LastId: 7, LastStamp: 50}, *cp)
+
+	columns, records, err := pkg.ReadColumnar(&buf)
+	assert.Equal(t, nil, err)
+	assert.Equal(t, pkg.OrderColumns, columns)
+	var ids []string
+	for _, r := range records {
+		ids = append(ids, r[0])
+	}
+	assert.Equal(t, []string{"4", "5", "6", "7"}, ids)
+	assert.Equal(t, "30", records[1][len(columns)-1])
+}
+
+func TestExportOrdersStopsAtOpenOrder(t *testing.T) {
+	open := int32(6)
+	server, _ := newHistoryServerWithOpen(&open)
+	defer server.Close()
+	service := pkg.NewService(server.URL, pkg.WithSigner(&pkg.HmacSigner{Key: []byte("your secret key")}))
+
+	var buf bytes.Buffer
+	w, err := pkg.NewRecordWriter(pkg.FormatCSV, &buf, pkg.OrderColumns, false)
+	assert.Equal(t, nil, err)
+	cp := &pkg.ExportCheckpoint{LastId: 3, LastStamp: 20}
+	n, err := pkg.ExportOrders(context.Background(), service, "", w, cp)
+	assert.Equal(t, nil, err)
+	assert.Equal(t, 2, n)
+	assert.Equal(t, pkg.ExportCheckpoint{LastId: 5, LastStamp: 30}, *cp)
+
+	// Once order 6 is done it is exported, with what follows it.
+	atomic.StoreInt32(&open, 0)
+	n, err = pkg.ExportOrders(context.Background(), service, "", w, cp)
+	assert.Equal(t, nil, err)
+	assert.Equal(t, 2, n)
+	assert.Equal(t, pkg.ExportCheckpoint{LastId: 7, LastStamp: 50}, *cp)
+
+	records, err := csv.NewReader(&buf).ReadAll()
+	assert.Equal(t, nil, err)
+	assert.Equal(t, 5, len(records))
+	assert.Equal(t, "done", records[3][5])
+}
+
+func TestColumnarAppend(t *testing.T) {
+	dir, err := ioutil.TempDir("", "columnar")
+	assert.Equal(t, nil, err)
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "trades.col")
+
+	for _, id := range []string{"1", "2"} {
+		f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0644)
+		assert.Equal(t, nil, err)
+		w, err := pkg.NewRecordWriter(pkg.FormatColumnar, f, []string{"id", "price"}, true)
+		assert.Equal(t, nil, err)
+		assert.Equal(t, nil, w.Write([]string{id, "0.5"}))
+		assert.Equal(t, nil, w.Flush())
+		f.Close()
+	}
+
+	f, err := os.OpenFile(path, os.O_RDWR, 0644)
+	assert.Equal(t, nil, err)
+	defer f.Close()
+	_, err = pkg.NewRecordWriter(pkg.FormatColumnar, f, []string{"id", "price", "qty"}, true)
+	assert.NotEqual(t, nil, err)
+	_, err = pkg.NewRecordWriter(pkg.FormatColumnar, &bytes.Buffer{}, []string{"id", "price"}, true)
+	assert.NotEqual(t, nil, err)
+
+	f.Seek(0, 0)
+	columns, records, err := pkg.ReadColumnar(f)
+	assert.Equal(t, nil, err)
+	assert.Equal(t, []string{"id", "price"}, columns)
+	assert.Equal(t, [][]string{{"1", "0.5"}, {"2", "0.5"}}, records)
+}
+
+func TestColumnarCorruptCounts(t *testing.T) {
+	header := "WONCOL1\n\x01\x02id"
+	for _, data := range []string{
+		"WONCOL1\n\xff\xff\xff\xff\x0f",
+		"WONCOL1\n\x01\xff\xff\xff\xff\x0f",
+		header + "\xff\xff\xff\xff\x0f",
+	} {
+		_, _, err := pkg.ReadColumnar(strings.NewReader(data))
+		assert.NotEqual(t, nil, err)
+	}
+}
+
+func TestExportJSONL(t *testing.T) {
+	var buf bytes.Buffer
+	w, err := pkg.NewRecordWriter(pkg.FormatJSONL, &buf, []string{"id", "price"}, false)
+	assert.Equal(t, nil, err)
+	assert.Equal(t, nil, w.Write([]string{"1", "0.5"}))
+	assert.Equal(t, nil, w.Flush())
+
+	var row map[string]string
+	assert.Equal(t, nil, json.Unmarshal([]byte(strings.TrimSpace(buf.String())), &row))
+	assert.Equal(t, map[string]string{"id": "1", "price": "0.5"}, row)
+}
+
+func TestExportCheckpointFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "export")
+	assert.Equal(t, nil, err)
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "trades.checkpoint")
+
+	cp, err := pkg.LoadCheckpoint(path)
+	assert.Equal(t, nil, err)
+	assert.Equal(t, pkg.ExportCheckpoint{}, *cp)
+
+	cp.LastId = 42
+	assert.Equal(t, nil, cp.Save(path))
+	cp, err = pkg.LoadCheckpoint(path)
+	assert.Equal(t, nil, err)
+	assert.Equal(t, int64(42), cp.LastId)
+}
@@ -0,0 +1,101 @@
+package tests
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync/atomic"
+	"testing"
+
+	"github.com/bmizerany/assert"
+	"github.com/xiangxian/exchange/pkg"
+)
+
+// newHistoryServer serves orders 1 to 7, created at stamps 10, 20, 20, 20,
+// 30, 40, 50, and trades 1 to 7, honouring start_at_stamp, end_at_stamp,
+// from_id and limit.
+func newHistoryServer() (*httptest.Server, *int32) {
+	var open int32
+	return newHistoryServerWithOpen(&open)
+}
+
+// newHistoryServerWithOpen is newHistoryServer with every order done except
+// the one whose id is in open, if any.
+func newHistoryServerWithOpen(open *int32) (*httptest.Server, *int32) {
+	stamps := []int64{10, 20, 20, 20, 30, 40, 50}
+	var pages int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&pages, 1)
+		q := r.URL.Query()
+		limit, _ := strconv.Atoi(q.Get("limit"))
+		var data []map[string]interface{}
+		switch r.URL.Path {
+		case "/api/v1/orders":
+			start, _ := strconv.ParseInt(q.Get("start_at_stamp"), 10, 64)
+			end, _ := strconv.ParseInt(q.Get("end_at_stamp"), 10, 64)
+			for i, stamp := range stamps {
+				if stamp >= start && (end == 0 || stamp <= end) && len(data) < limit {
+					state := pkg.StateDone
+					if int32(i+1) == atomic.LoadInt32(open) {
+						state = pkg.StateWait
+					}
+					data = append(data, map[string]interface{}{"id": i + 1, "created_at_stamp": stamp, "state": state})
+				}
+			}
+		case "/api/v1/trades/my":
+			from, _ := strconv.Atoi(q.Get("from_id"))
+			for id := from; id <= 7 && len(data) < limit; id++ {
+				data = append(data, map[string]interface{}{"id": id})
+			}
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{"data": data})
+	}))
+	return server, &pages
+}
+
+func TestOrdersIterator(t *testing.T) {
+	server, pages := newHistoryServer()
+	defer server.Close()
+	service := pkg.NewService(server.URL, pkg.WithSigner(&pkg.HmacSigner{Key: []byte("your secret key")}))
+
+	it := pkg.NewOrdersIterator(context.Background(), service, pkg.OrdersRequest{Limit: 4})
+	var ids []int64
+	for it.Next() {
+		ids = append(ids, it.Order().Id)
+	}
+	assert.Equal(t, nil, it.Err())
+	assert.Equal(t, []int64{1, 2, 3, 4, 5, 6, 7}, ids)
+	assert.Equal(t, true, atomic.LoadInt32(pages) > 2)
+
+	it = pkg.NewOrdersIterator(context.Background(), service, pkg.OrdersRequest{Limit: 2, StartAtStamp: 20, EndAtStamp: 30})
+	ids = nil
+	for it.Next() {
+		ids = append(ids, it.Order().Id)
+	}
+	// Three orders share stamp 20, more than a page of two can skip past.
+	assert.NotEqual(t, nil, it.Err())
+	assert.Equal(t, []int64{2, 3}, ids)
+}
+
+func TestMyTradesIterator(t *testing.T) {
+	server, _ := newHistoryServer()
+	defer server.Close()
+	service := pkg.NewService(server.URL, pkg.WithSigner(&pkg.HmacSigner{Key: []byte("your secret key")}))
+
+	it := pkg.NewMyTradesIterator(context.Background(), service, pkg.TradeRequest{Market: "wonbtc", Limit: 3, FromId: 2})
+	var ids []int64
+	for it.Next() {
+		ids = append(ids, it.Trade().Id)
+	}
+	assert.Equal(t, nil, it.Err())
+	assert.Equal(t, []int64{2, 3, 4, 5, 6, 7}, ids)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	it = pkg.NewMyTradesIterator(ctx, service, pkg.TradeRequest{Market: "wonbtc", Limit: 3})
+	assert.Equal(t, true, it.Next())
+	cancel()
+	assert.Equal(t, false, it.Next())
+	assert.Equal(t, context.Canceled, it.Err())
+}
@@ -0,0 +1,117 @@
+package tests
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/bmizerany/assert"
+	"github.com/xiangxian/exchange/pkg"
+)
+
+const exchangeInfoBody = `{"data":{"time":1,"markets":[
+	{"market":"wonbtc","base_currency":"won","quote_currency":"btc","price_tick":"0.00000001",
+	 "volume_step":"1","min_volume":"10","max_volume":"1000000","min_notional":"0.0001","status":"trading"},
+	{"market":"woneth","base_currency":"won","quote_currency":"eth","price_tick":"0.000001",
+	 "volume_step":"0.1","min_volume":"1","max_volume":"0","min_notional":"0","status":"halted"}]}}`
+
+func newMarketServer() (*httptest.Server, *int32) {
+	var hits int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&hits, 1)
+		w.Write([]byte(exchangeInfoBody))
+	}))
+	return server, &hits
+}
+
+func TestExchangeInfo(t *testing.T) {
+	server, hits := newMarketServer()
+	defer server.Close()
+
+	service := pkg.NewService(server.URL)
+	info, err := service.ExchangeInfo()
+	assert.Equal(t, nil, err)
+	assert.Equal(t, 2, len(info.Markets))
+
+	market, err := service.MarketInfo("wonbtc")
+	assert.Equal(t, nil, err)
+	assert.Equal(t, "btc", market.QuoteCurrency)
+	assert.Equal(t, "0.00000001", market.PriceTick.String())
+	assert.Equal(t, "0.0001", market.MinNotional.String())
+	assert.Equal(t, true, market.Trading())
+
+	market, _ = service.MarketInfo("woneth")
+	assert.Equal(t, false, market.Trading())
+
+	_, err = service.MarketInfo("nope")
+	assert.Equal(t, true, pkg.IsUnknownMarket(err))
+	assert.Equal(t, int32(1), atomic.LoadInt32(hits))
+}
+
+func TestExchangeInfoCacheExpires(t *testing.T) {
+	server, hits := newMarketServer()
+	defer server.Close()
+
+	now := time.Unix(1500000000, 0)
+	service := pkg.NewService(server.URL,
+		pkg.WithClock(func() time.Time { return now }),
+		pkg.WithMarketCacheTTL(time.Minute))
+	service.ExchangeInfo()
+	now = now.Add(30 * time.Second)
+	service.ExchangeInfo()
+	assert.Equal(t, int32(1), atomic.LoadInt32(hits))
+	now = now.Add(time.Minute)
+	service.ExchangeInfo()
+	assert.Equal(t, int32(2), atomic.LoadInt32(hits))
+}
+
+func TestExchangeInfoSharedFetch(t *testing.T) {
+	var hits int32
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&hits, 1)
+		<-release
+		w.Write([]byte(exchangeInfoBody))
+	}))
+	defer server.Close()
+	service := pkg.NewService(server.URL)
+
+	results := make(chan error, 5)
+	for i := 0; i < 5; i++ {
+		go func() {
+			_, err := service.ExchangeInfo()
+			results <- err
+		}()
+	}
+	waitFor(t, func() bool { return atomic.LoadInt32(&hits) == 1 })
+
+	// A caller does not wait on the request in flight past its own deadline.
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	_, err := service.ExchangeInfoCtx(ctx)
+	assert.Equal(t, context.DeadlineExceeded, err)
+
+	close(release)
+	for i := 0; i < 5; i++ {
+		assert.Equal(t, nil, <-results)
+	}
+	assert.Equal(t, int32(1), atomic.LoadInt32(&hits))
+}
+
+func TestExchangeInfoReturnsCopy(t *testing.T) {
+	server, _ := newMarketServer()
+	defer server.Close()
+
+	service := pkg.NewService(server.URL)
+	info, err := service.ExchangeInfo()
+	assert.Equal(t, nil, err)
+	info.Markets[0].Status = "halted"
+	info.Markets = info.Markets[:1]
+
+	info, _ = service.ExchangeInfo()
+	assert.Equal(t, 2, len(info.Markets))
+	assert.Equal(t, pkg.MarketTrading, info.Markets[0].Status)
+}
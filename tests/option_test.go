@@ -0,0 +1,54 @@
+package tests
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/bmizerany/assert"
+	"github.com/xiangxian/exchange/pkg"
+)
+
+func TestServiceOptions(t *testing.T) {
+	var got *http.Request
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = r
+		w.Write([]byte(`{"data":{"id":1495,"state":"wait"}}`))
+	}))
+	defer server.Close()
+
+	service := pkg.NewService(server.URL,
+		pkg.WithAPIKey("api key"),
+		pkg.WithSigner(&pkg.HmacSigner{Key: []byte("your secret key")}),
+		pkg.WithUserAgent("won-test/1.0"),
+		pkg.WithRecvWindow(5000),
+		pkg.WithClock(func() time.Time { return time.Unix(1500000000, 0) }))
+
+	order, err := service.GetOrder(pkg.OrderRequest{Id: 1495})
+	assert.Equal(t, nil, err)
+	assert.Equal(t, int64(1495), order.Id)
+	assert.Equal(t, "api key", got.Header.Get("X-Won-Apikey"))
+	assert.Equal(t, "won-test/1.0", got.Header.Get("User-Agent"))
+	assert.Equal(t, "1500000000000", got.URL.Query().Get("timestamp"))
+	assert.Equal(t, "5000", got.URL.Query().Get("recv_window"))
+	assert.NotEqual(t, "", got.URL.Query().Get("signature"))
+}
+
+func TestServiceOptionsExplicitStamp(t *testing.T) {
+	var got *http.Request
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = r
+		w.Write([]byte(`{"data":{"id":1495,"state":"wait"}}`))
+	}))
+	defer server.Close()
+
+	service := pkg.NewService(server.URL,
+		pkg.WithSigner(&pkg.HmacSigner{Key: []byte("your secret key")}),
+		pkg.WithRecvWindow(5000))
+
+	_, err := service.GetOrder(pkg.OrderRequest{Id: 1495, Timestamp: 1600000000000, RecvWindow: 1000})
+	assert.Equal(t, nil, err)
+	assert.Equal(t, "1600000000000", got.URL.Query().Get("timestamp"))
+	assert.Equal(t, "1000", got.URL.Query().Get("recv_window"))
+}
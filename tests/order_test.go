@@ -0,0 +1,73 @@
+package tests
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/bmizerany/assert"
+	"github.com/xiangxian/exchange/pkg"
+)
+
+func TestOrderBuildersParams(t *testing.T) {
+	server, orders := newTradingServer()
+	defer server.Close()
+
+	service := pkg.NewService(server.URL, pkg.WithSigner(&pkg.HmacSigner{Key: []byte("your secret key")}))
+	d := pkg.MustDecimal
+	requests := []pkg.CreateOrderRequest{
+		pkg.LimitOrder("wonbtc", pkg.SideBuy, d("0.00001"), d("100")).WithPostOnly(),
+		pkg.MarketOrderByFunds("wonbtc", pkg.SideBuy, d("0.001")).WithTimeInForce(pkg.IOC),
+		pkg.StopLossLimitOrder("wonbtc", pkg.SideSell, d("0.000009"), d("0.0000089"), d("100")),
+	}
+	for _, r := range requests {
+		_, err := service.CreateOrder(r)
+		assert.Equal(t, nil, err)
+	}
+
+	sent := orders()
+	assert.Equal(t, 3, len(sent))
+	assert.Equal(t, "true", sent[0].Get("post_only"))
+	assert.Equal(t, "0.00001", sent[0].Get("price"))
+
+	assert.Equal(t, "market", sent[1].Get("ord_type"))
+	assert.Equal(t, "0.001", sent[1].Get("funds"))
+	assert.Equal(t, "ioc", sent[1].Get("time_in_force"))
+	_, hasPrice := sent[1]["price"]
+	_, hasVolume := sent[1]["volume"]
+	assert.Equal(t, false, hasPrice || hasVolume)
+
+	assert.Equal(t, "stop_loss_limit", sent[2].Get("ord_type"))
+	assert.Equal(t, "0.000009", sent[2].Get("stop_price"))
+	assert.Equal(t, "0.0000089", sent[2].Get("price"))
+}
+
+func TestOrderCheck(t *testing.T) {
+	d := pkg.MustDecimal
+	valid := []pkg.CreateOrderRequest{
+		pkg.LimitOrder("wonbtc", pkg.SideBuy, d("1"), d("1")).WithTimeInForce(pkg.FOK),
+		pkg.MarketOrder("wonbtc", pkg.SideSell, d("1")),
+		pkg.StopLossOrder("wonbtc", pkg.SideSell, d("1"), d("1")),
+		pkg.TakeProfitLimitOrder("wonbtc", pkg.SideSell, d("2"), d("2"), d("1")).WithPostOnly(),
+	}
+	for _, r := range valid {
+		assert.Equal(t, nil, r.Check())
+	}
+
+	withFunds := pkg.MarketOrder("wonbtc", pkg.SideBuy, d("1"))
+	withFunds.Funds = d("1")
+	invalid := map[string]pkg.CreateOrderRequest{
+		"price":         pkg.LimitOrder("wonbtc", pkg.SideBuy, d("0"), d("1")),
+		"stop_price":    pkg.TakeProfitOrder("wonbtc", pkg.SideSell, d("0"), d("1")),
+		"funds":         withFunds,
+		"volume":        pkg.StopLossOrder("wonbtc", pkg.SideSell, d("1"), d("0")),
+		"time_in_force": pkg.MarketOrder("wonbtc", pkg.SideSell, d("1")).WithTimeInForce(pkg.GTC),
+		"post_only":     pkg.MarketOrder("wonbtc", pkg.SideSell, d("1")).WithPostOnly(),
+	}
+	for field, r := range invalid {
+		err := r.Check()
+		assert.Equal(t, true, errors.Is(err, pkg.ErrInvalidOrder), field)
+		assert.Equal(t, field, err.(*pkg.ValidationError).Field)
+	}
+	err := pkg.LimitOrder("wonbtc", pkg.SideBuy, d("1"), d("1")).WithPostOnly().WithTimeInForce(pkg.IOC).Check()
+	assert.Equal(t, "post_only", err.(*pkg.ValidationError).Field)
+}
@@ -0,0 +1,156 @@
+package tests
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/bmizerany/assert"
+	"github.com/xiangxian/exchange/pkg"
+)
+
+// newDepthServer serves depth snapshots whose last_update_id grows by 10 on
+// every request, starting at 10.
+func newDepthServer() (*httptest.Server, *int32) {
+	var snapshots int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := atomic.AddInt32(&snapshots, 1)
+		fmt.Fprintf(w, `{"data":{"time":1,"last_update_id":%d,
+			"bids":[["0.100","5"],["0.099","10"],["0.098","20"]],
+			"asks":[["0.101","4"],["0.102","8"],["0.104","16"]]}}`, n*10)
+	}))
+	return server, &snapshots
+}
+
+func level(price, amount string) pkg.PriceLevel {
+	return pkg.PriceLevel{Price: pkg.MustDecimal(price), Amount: pkg.MustDecimal(amount)}
+}
+
+func syncedBook(t *testing.T) (*pkg.OrderBook, *int32, func()) {
+	server, snapshots := newDepthServer()
+	book := pkg.NewOrderBook("wonbtc", pkg.NewService(server.URL), 100)
+	assert.Equal(t, nil, book.Sync(context.Background()))
+	return book, snapshots, server.Close
+}
+
+func TestOrderBookQueries(t *testing.T) {
+	book, _, done := syncedBook(t)
+	defer done()
+
+	bid, _ := book.BestBid()
+	ask, _ := book.BestAsk()
+	assert.Equal(t, "0.100", bid.Price.String())
+	assert.Equal(t, "0.101", ask.Price.String())
+
+	spread, err := book.Spread()
+	assert.Equal(t, nil, err)
+	assert.Equal(t, "0.001", spread.String())
+	mid, _ := book.MidPrice()
+	assert.Equal(t, "0.1005", mid.String())
+
+	volume, _ := book.CumulativeVolume(pkg.Bids, pkg.MustDecimal("0.099"))
+	assert.Equal(t, "15", volume.String())
+	volume, _ = book.CumulativeVolume(pkg.Asks, pkg.MustDecimal("0.103"))
+	assert.Equal(t, "12", volume.String())
+
+	// 4 at 0.101 and 6 at 0.102.
+	vwap, err := book.VWAP(pkg.Asks, pkg.MustDecimal("10"))
+	assert.Equal(t, nil, err)
+	assert.Equal(t, "0.1016", vwap.String())
+	_, err = book.VWAP(pkg.Bids, pkg.MustDecimal("1000"))
+	assert.Equal(t, pkg.ErrInsufficientDepth, err)
+}
+
+func TestOrderBookApply(t *testing.T) {
+	book, _, done := syncedBook(t)
+	defer done()
+
+	// Already contained in the snapshot.
+	assert.Equal(t, nil, book.Apply(pkg.DepthUpdate{FirstUpdateId: 5, LastUpdateId: 9,
+		Bids: []pkg.PriceLevel{level("0.5", "1")}}))
+	bid, _ := book.BestBid()
+	assert.Equal(t, "0.100", bid.Price.String())
+
+	assert.Equal(t, nil, book.Apply(pkg.DepthUpdate{FirstUpdateId: 9, LastUpdateId: 12,
+		Bids: []pkg.PriceLevel{level("0.100", "0"), level("0.0995", "3")},
+		Asks: []pkg.PriceLevel{level("0.1005", "1")}}))
+	bid, _ = book.BestBid()
+	ask, _ := book.BestAsk()
+	assert.Equal(t, "0.0995", bid.Price.String())
+	assert.Equal(t, "0.1005", ask.Price.String())
+	assert.Equal(t, int64(12), book.LastUpdateId())
+	assert.Equal(t, []pkg.PriceLevel{level("0.0995", "3"), level("0.099", "10")}, book.Levels(pkg.Bids, 2))
+}
+
+func TestOrderBookGapResync(t *testing.T) {
+	book, snapshots, done := syncedBook(t)
+	defer done()
+
+	err := book.Apply(pkg.DepthUpdate{FirstUpdateId: 15, LastUpdateId: 21,
+		Asks: []pkg.PriceLevel{level("0.101", "0")}})
+	assert.Equal(t, pkg.ErrOrderBookGap, err)
+	assert.Equal(t, false, book.Synced())
+	_, err = book.Spread()
+	assert.Equal(t, pkg.ErrOrderBookNotSynced, err)
+
+	// The next snapshot ends at 20, so the buffered update applies on top.
+	assert.Equal(t, nil, book.Sync(context.Background()))
+	assert.Equal(t, int32(2), atomic.LoadInt32(snapshots))
+	assert.Equal(t, int64(21), book.LastUpdateId())
+	ask, _ := book.BestAsk()
+	assert.Equal(t, "0.102", ask.Price.String())
+}
+
+func TestOrderBookFollow(t *testing.T) {
+	server, snapshots := newDepthServer()
+	defer server.Close()
+	book := pkg.NewOrderBook("wonbtc", pkg.NewService(server.URL), 100)
+	book.Resync = fastRetry
+
+	updates := make(chan pkg.DepthUpdate, 3)
+	updates <- pkg.DepthUpdate{FirstUpdateId: 11, LastUpdateId: 11, Bids: []pkg.PriceLevel{level("0.1", "0")}}
+	updates <- pkg.DepthUpdate{FirstUpdateId: 25, LastUpdateId: 31, Bids: []pkg.PriceLevel{level("0.0999", "1")}}
+	close(updates)
+
+	assert.Equal(t, nil, book.Follow(context.Background(), updates))
+	assert.Equal(t, true, atomic.LoadInt32(snapshots) >= 2)
+	assert.Equal(t, int64(31), book.LastUpdateId())
+	bid, _ := book.BestBid()
+	assert.Equal(t, "0.100", bid.Price.String())
+}
+
+func TestOrderBookFollowStaleSnapshots(t *testing.T) {
+	var snapshots int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&snapshots, 1)
+		w.Write([]byte(`{"data":{"time":1,"last_update_id":10,"bids":[],"asks":[]}}`))
+	}))
+	defer server.Close()
+	book := pkg.NewOrderBook("wonbtc", pkg.NewService(server.URL), 100)
+	book.Resync = pkg.RetryPolicy{MaxAttempts: 4, BaseDelay: 10 * time.Millisecond}
+
+	updates := make(chan pkg.DepthUpdate, 1)
+	updates <- pkg.DepthUpdate{FirstUpdateId: 25, LastUpdateId: 31}
+	start := time.Now()
+	assert.Equal(t, pkg.ErrOrderBookGap, book.Follow(context.Background(), updates))
+	// The initial sync, then 4 attempts 10, 20 and 40ms apart.
+	assert.Equal(t, int32(5), atomic.LoadInt32(&snapshots))
+	assert.Equal(t, true, time.Since(start) >= 70*time.Millisecond)
+}
+
+func TestOrderBookPendingBounded(t *testing.T) {
+	server, _ := newDepthServer()
+	defer server.Close()
+	book := pkg.NewOrderBook("wonbtc", pkg.NewService(server.URL), 100)
+
+	for id := int64(1); id <= 1500; id++ {
+		assert.Equal(t, nil, book.Apply(pkg.DepthUpdate{FirstUpdateId: id, LastUpdateId: id}))
+	}
+	// Only the last 1000 updates are kept, so the snapshot at 10 no longer
+	// reaches them.
+	assert.Equal(t, pkg.ErrOrderBookGap, book.Sync(context.Background()))
+}
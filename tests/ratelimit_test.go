@@ -0,0 +1,111 @@
+package tests
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/bmizerany/assert"
+	"github.com/xiangxian/exchange/pkg"
+)
+
+func newCountingServer(body string) (*httptest.Server, *int32) {
+	var hits int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&hits, 1)
+		w.Write([]byte(body))
+	}))
+	return server, &hits
+}
+
+func TestRateLimitFailFast(t *testing.T) {
+	server, hits := newCountingServer(`{"data":{"id":1495}}`)
+	defer server.Close()
+
+	service := pkg.NewService(server.URL,
+		pkg.WithSigner(&pkg.HmacSigner{Key: []byte("your secret key")}),
+		pkg.WithRateLimit(pkg.PrivateBucket, 1, 2),
+		pkg.WithRateLimitPolicy(pkg.RateLimitFailFast))
+
+	for i := 0; i < 2; i++ {
+		_, err := service.GetOrder(pkg.OrderRequest{Id: 1495})
+		assert.Equal(t, nil, err)
+	}
+	_, err := service.GetOrder(pkg.OrderRequest{Id: 1495})
+	assert.Equal(t, true, pkg.IsRateLimited(err))
+	rlErr, ok := err.(*pkg.RateLimitError)
+	assert.Equal(t, true, ok)
+	assert.Equal(t, pkg.PrivateBucket, rlErr.Bucket)
+	assert.Equal(t, int32(2), atomic.LoadInt32(hits))
+
+	budget, ok := service.RateLimitBudget(pkg.PrivateBucket)
+	assert.Equal(t, true, ok)
+	assert.Equal(t, true, budget.Tokens < 1)
+	assert.Equal(t, 2, budget.Burst)
+
+	_, ok = service.RateLimitBudget(pkg.PublicBucket)
+	assert.Equal(t, false, ok)
+}
+
+func TestRateLimitBucketsAreSeparate(t *testing.T) {
+	server, _ := newCountingServer(`{"data":{"market":"wonbtc","price":"0.00001"}}`)
+	defer server.Close()
+
+	service := pkg.NewService(server.URL,
+		pkg.WithRateLimit(pkg.PrivateBucket, 1, 1),
+		pkg.WithRateLimit(pkg.PublicBucket, 1000, 10),
+		pkg.WithRateLimitPolicy(pkg.RateLimitFailFast))
+	for i := 0; i < 5; i++ {
+		_, err := service.TickerPrice(pkg.TickerPriceRequest{Market: "wonbtc"})
+		assert.Equal(t, nil, err)
+	}
+	budget, _ := service.RateLimitBudget(pkg.PrivateBucket)
+	assert.Equal(t, float64(1), budget.Tokens)
+}
+
+func TestRateLimitEndpointWeight(t *testing.T) {
+	server, _ := newCountingServer(`{"data":{"time":0,"bids":[],"asks":[]}}`)
+	defer server.Close()
+
+	service := pkg.NewService(server.URL,
+		pkg.WithRateLimit(pkg.PublicBucket, 1, 10),
+		pkg.WithEndpointWeight("api/v1/depth", 5),
+		pkg.WithRateLimitPolicy(pkg.RateLimitFailFast))
+	_, err := service.Depth(pkg.DepthRequest{Market: "wonbtc"})
+	assert.Equal(t, nil, err)
+	_, err = service.Depth(pkg.DepthRequest{Market: "wonbtc"})
+	assert.Equal(t, nil, err)
+	_, err = service.Depth(pkg.DepthRequest{Market: "wonbtc"})
+	assert.Equal(t, true, pkg.IsRateLimited(err))
+}
+
+func TestRateLimitWait(t *testing.T) {
+	server, hits := newCountingServer(`{"data":{"market":"wonbtc","price":"0.00001"}}`)
+	defer server.Close()
+
+	service := pkg.NewService(server.URL, pkg.WithRateLimit(pkg.PublicBucket, 20, 1))
+	start := time.Now()
+	for i := 0; i < 3; i++ {
+		_, err := service.TickerPrice(pkg.TickerPriceRequest{Market: "wonbtc"})
+		assert.Equal(t, nil, err)
+	}
+	assert.Equal(t, true, time.Since(start) >= 90*time.Millisecond)
+	assert.Equal(t, int32(3), atomic.LoadInt32(hits))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	service.TickerPrice(pkg.TickerPriceRequest{Market: "wonbtc"})
+	_, err := service.TickerPriceCtx(ctx, pkg.TickerPriceRequest{Market: "wonbtc"})
+	assert.Equal(t, context.DeadlineExceeded, err)
+}
+
+func TestTokenBucket(t *testing.T) {
+	tb := pkg.NewTokenBucket(100, 2)
+	assert.Equal(t, true, tb.Allow(1))
+	assert.Equal(t, true, tb.Allow(1))
+	assert.Equal(t, false, tb.Allow(1))
+	assert.Equal(t, nil, tb.Wait(context.Background(), 1))
+}
@@ -0,0 +1,164 @@
+package tests
+
+import (
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"sync"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/bmizerany/assert"
+	"github.com/xiangxian/exchange/pkg"
+)
+
+var fastRetry = pkg.RetryPolicy{MaxAttempts: 3, BaseDelay: time.Millisecond, MaxDelay: 10 * time.Millisecond}
+
+// flakyServer fails the first failures requests with status, then answers
+// with body. It records the query of every request it receives.
+type flakyServer struct {
+	*httptest.Server
+	mu      sync.Mutex
+	queries []map[string][]string
+}
+
+func newFlakyServer(failures int, status int, header http.Header, body string) *flakyServer {
+	fs := &flakyServer{}
+	fs.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fs.mu.Lock()
+		fs.queries = append(fs.queries, r.URL.Query())
+		n := len(fs.queries)
+		fs.mu.Unlock()
+		if n <= failures {
+			for k, v := range header {
+				w.Header()[k] = v
+			}
+			w.WriteHeader(status)
+			return
+		}
+		w.Write([]byte(body))
+	}))
+	return fs
+}
+
+func (fs *flakyServer) hits() int {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+	return len(fs.queries)
+}
+
+func TestRetryGet(t *testing.T) {
+	server := newFlakyServer(2, http.StatusServiceUnavailable, nil, `{"data":{"market":"wonbtc","price":"0.00001"}}`)
+	defer server.Close()
+
+	service := pkg.NewService(server.URL, pkg.WithRetryPolicy(fastRetry))
+	price, err := service.TickerPrice(pkg.TickerPriceRequest{Market: "wonbtc"})
+	assert.Equal(t, nil, err)
+	assert.Equal(t, "0.00001", price.Price.String())
+	assert.Equal(t, 3, server.hits())
+}
+
+func TestRetryGivesUp(t *testing.T) {
+	server := newFlakyServer(5, http.StatusBadGateway, nil, `{"data":{}}`)
+	defer server.Close()
+
+	service := pkg.NewService(server.URL, pkg.WithRetryPolicy(fastRetry))
+	_, err := service.Depth(pkg.DepthRequest{Market: "wonbtc"})
+	assert.NotEqual(t, nil, err)
+	assert.Equal(t, 3, server.hits())
+}
+
+func TestRetryResignsWithFreshTimestamp(t *testing.T) {
+	server := newFlakyServer(1, http.StatusInternalServerError, nil, `{"data":{"id":1495}}`)
+	defer server.Close()
+
+	now := time.Unix(1500000000, 0)
+	clock := func() time.Time {
+		now = now.Add(time.Second)
+		return now
+	}
+	service := pkg.NewService(server.URL,
+		pkg.WithSigner(&pkg.HmacSigner{Key: []byte("your secret key")}),
+		pkg.WithRetryPolicy(fastRetry),
+		pkg.WithClock(clock))
+	_, err := service.GetOrder(pkg.OrderRequest{Id: 1495})
+	assert.Equal(t, nil, err)
+	assert.Equal(t, 2, server.hits())
+	assert.NotEqual(t, server.queries[0]["timestamp"][0], server.queries[1]["timestamp"][0])
+	assert.NotEqual(t, server.queries[0]["signature"][0], server.queries[1]["signature"][0])
+}
+
+func TestRetryHonorsRetryAfter(t *testing.T) {
+	header := http.Header{"Retry-After": []string{"1"}}
+	server := newFlakyServer(1, http.StatusTooManyRequests, header, `{"data":{"market":"wonbtc","price":"0.00001"}}`)
+	defer server.Close()
+
+	service := pkg.NewService(server.URL, pkg.WithRetryPolicy(fastRetry))
+	start := time.Now()
+	_, err := service.TickerPrice(pkg.TickerPriceRequest{Market: "wonbtc"})
+	assert.Equal(t, nil, err)
+	assert.Equal(t, true, time.Since(start) >= time.Second)
+}
+
+func TestRetryNotAppliedToCreateOrder(t *testing.T) {
+	server := newFlakyServer(1, http.StatusServiceUnavailable, nil, `{"data":{"id":1}}`)
+	defer server.Close()
+
+	service := pkg.NewService(server.URL,
+		pkg.WithSigner(&pkg.HmacSigner{Key: []byte("your secret key")}),
+		pkg.WithRetryPolicy(fastRetry))
+	_, err := service.CreateOrder(pkg.CreateOrderRequest{Market: "wonbtc", Side: "buy", Volume: pkg.MustDecimal("1"), Price: pkg.MustDecimal("1"), OrdType: "limit"})
+	assert.NotEqual(t, nil, err)
+	assert.Equal(t, 1, server.hits())
+}
+
+func TestRetryCancelOrderOptIn(t *testing.T) {
+	signer := &pkg.HmacSigner{Key: []byte("your secret key")}
+	for _, optIn := range []bool{false, true} {
+		server := newFlakyServer(1, http.StatusServiceUnavailable, nil, `{"data":"success"}`)
+		policy := fastRetry
+		policy.RetryCancel = optIn
+		service := pkg.NewService(server.URL, pkg.WithSigner(signer), pkg.WithRetryPolicy(policy))
+		err := service.CancelOrder(pkg.CancelOrderRequest{Id: 1501})
+		server.Close()
+
+		assert.Equal(t, optIn, err == nil)
+		if optIn {
+			assert.Equal(t, 2, server.hits())
+		} else {
+			assert.Equal(t, 1, server.hits())
+		}
+	}
+}
+
+// failingTransport fails every request with err and counts them.
+type failingTransport struct {
+	err   error
+	tries int
+}
+
+func (ft *failingTransport) RoundTrip(*http.Request) (*http.Response, error) {
+	ft.tries++
+	return nil, ft.err
+}
+
+func TestRetryOnlyTransientNetworkErrors(t *testing.T) {
+	cases := []struct {
+		err   error
+		tries int
+	}{
+		{&net.OpError{Op: "dial", Net: "tcp", Err: &os.SyscallError{Syscall: "connect", Err: syscall.ECONNREFUSED}}, 3},
+		{&net.OpError{Op: "read", Net: "tcp", Err: &os.SyscallError{Syscall: "read", Err: syscall.ECONNRESET}}, 3},
+		{errors.New("x509: certificate signed by unknown authority"), 1},
+	}
+	for _, c := range cases {
+		transport := &failingTransport{err: c.err}
+		service := pkg.NewService("http://127.0.0.1", pkg.WithHTTPClient(&http.Client{Transport: transport}), pkg.WithRetryPolicy(fastRetry))
+		_, err := service.Depth(pkg.DepthRequest{Market: "wonbtc"})
+		assert.NotEqual(t, nil, err)
+		assert.Equal(t, c.tries, transport.tries, c.err.Error())
+	}
+}
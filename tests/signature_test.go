@@ -0,0 +1,175 @@
+package tests
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/bmizerany/assert"
+	"github.com/xiangxian/exchange/pkg"
+)
+
+// signedRequest is a signed request as the exchange received it.
+type signedRequest struct {
+	payload   pkg.SignPayload
+	signature string
+}
+
+// newSigningServer records the requests signed under the canonical scheme
+// and answers every request with empty data.
+func newSigningServer(requests *[]signedRequest) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		params := r.URL.Query()
+		if r.Header.Get("Content-Type") == "application/x-www-form-urlencoded" {
+			params, _ = url.ParseQuery(string(body))
+		}
+		if signature := r.Header.Get("X-Won-Signature"); signature != "" {
+			*requests = append(*requests, signedRequest{
+				payload: pkg.SignPayload{
+					Method:    r.Method,
+					Path:      r.URL.EscapedPath(),
+					Query:     r.URL.RawQuery,
+					Body:      body,
+					Timestamp: params.Get("timestamp"),
+					APIKey:    r.Header.Get("X-Won-Apikey"),
+				},
+				signature: signature,
+			})
+		}
+		w.Write([]byte(`{"data":null}`))
+	}))
+}
+
+func TestCanonicalSignatures(t *testing.T) {
+	var requests []signedRequest
+	server := newSigningServer(&requests)
+	defer server.Close()
+	signer := &pkg.HmacSigner{Key: []byte("your secret key")}
+	service := pkg.NewService(server.URL,
+		pkg.WithAPIKey("your api key"),
+		pkg.WithSigner(signer),
+		pkg.WithClock(func() time.Time { return time.Unix(1600000000, 0) }),
+		pkg.WithSigningScheme(pkg.SignCanonical),
+		pkg.WithBodyEncoding(pkg.BodyForm),
+		pkg.WithBatchEndpoints(10),
+		pkg.WithAmendEndpoint(),
+		pkg.WithRetryPolicy(pkg.RetryPolicy{MaxAttempts: 1}),
+	)
+	ctx := context.Background()
+	order := pkg.LimitOrder("wonbtc", pkg.SideBuy, pkg.MustDecimal("0.5"), pkg.MustDecimal("2"))
+	order.ClientOrderId = "c1"
+
+	cases := []struct {
+		name      string
+		call      func()
+		payload   string
+		signature string
+	}{
+		{
+			"mytrades",
+			func() { service.MyTradesCtx(ctx, pkg.TradeRequest{Market: "wonbtc", Limit: 10, FromId: 5}) },
+			"GET\n/api/v1/trades/my\nfrom_id=5&limit=10&market=wonbtc&recv_window=5000&timestamp=1600000000000\n1600000000000\nyour api key\n",
+			"a45faf1481e648ecf36525f6fb19017d5e83f5b41c55bf460aa1acb66e811d1a",
+		},
+		{
+			"account",
+			func() { service.AccountCtx(ctx, pkg.AccountRequest{}) },
+			"GET\n/api/v1/account\nrecv_window=5000&timestamp=1600000000000\n1600000000000\nyour api key\n",
+			"1d3c04138439ca4729d21663f7d8cc6051f2b2d66488506d2482df09dd008eb0",
+		},
+		{
+			"create order",
+			func() { service.CreateOrderCtx(ctx, order) },
+			"POST\n/api/v1/order/create\n\n1600000000000\nyour api key\n" +
+				"client_order_id=c1&market=wonbtc&ord_type=limit&price=0.5&recv_window=5000&side=buy&timestamp=1600000000000&volume=2",
+			"6d04e172112291aa91bfe56f8272973d5b511e5272f92404b486b82e9ea57225",
+		},
+		{
+			"orders",
+			func() { service.GetOrdersCtx(ctx, pkg.OrdersRequest{Market: "wonbtc", State: pkg.StateWait}) },
+			"GET\n/api/v1/orders\nend_at_stamp=0&market=wonbtc&order_id=0&recv_window=5000&start_at_stamp=0&state=wait&timestamp=1600000000000\n1600000000000\nyour api key\n",
+			"39e96e77d652121bfadfa50786b46ba64122dc1e006b9aeeffee17aaebb5321a",
+		},
+		{
+			"order",
+			func() { service.GetOrderCtx(ctx, pkg.OrderRequest{Id: 7}) },
+			"GET\n/api/v1/order\nid=7&recv_window=5000&timestamp=1600000000000\n1600000000000\nyour api key\n",
+			"d80dbd8cf3146d22c35d2f49cf3da330909dc48fb40faf2ee4b009decd2ef1b0",
+		},
+		{
+			"cancel order",
+			func() { service.CancelOrderCtx(ctx, pkg.CancelOrderRequest{Id: 7}) },
+			"POST\n/api/v1/order/cancel\n\n1600000000000\nyour api key\nid=7&recv_window=5000&timestamp=1600000000000",
+			"0c0844372090df38ca31c1bdd4e5232bd9d6e3fd2e8b63240cb9d0869a6fa3f5",
+		},
+		{
+			"create batch",
+			func() { service.CreateOrdersCtx(ctx, []pkg.CreateOrderRequest{order}) },
+			"POST\n/api/v1/orders/create_batch\n\n1600000000000\nyour api key\n" +
+				"orders=%5B%7B%22client_order_id%22%3A%22c1%22%2C%22market%22%3A%22wonbtc%22%2C%22ord_type%22%3A%22limit%22%2C%22price%22%3A%220.5%22%2C%22side%22%3A%22buy%22%2C%22volume%22%3A%222%22%7D%5D" +
+				"&recv_window=5000&timestamp=1600000000000",
+			"dd7aa07042edb89572585bfc33f3022df08062044654cab0578838e8a37a5cf6",
+		},
+		{
+			"cancel batch",
+			func() { service.CancelOrdersCtx(ctx, []int64{7, 8}) },
+			"POST\n/api/v1/orders/cancel_batch\n\n1600000000000\nyour api key\nids=7%2C8&recv_window=5000&timestamp=1600000000000",
+			"633a8672cdb5815826ce551a555f2bc21862e0b257e2ab7618a28d241df55911",
+		},
+		{
+			"replace order",
+			func() { service.AmendOrderCtx(ctx, pkg.AmendOrderRequest{Id: 7, Order: order}) },
+			"POST\n/api/v1/order/replace\n\n1600000000000\nyour api key\n" +
+				"client_order_id=c1&id=7&market=wonbtc&ord_type=limit&price=0.5&recv_window=5000&side=buy&timestamp=1600000000000&volume=2",
+			"f93f4027571b7a3da34593da263c85bf8234dc9aef029846775d13e2ed2cd228",
+		},
+	}
+	for _, c := range cases {
+		requests = nil
+		c.call()
+		assert.Equal(t, 1, len(requests), c.name)
+		if len(requests) == 0 {
+			continue
+		}
+		r := requests[0]
+		assert.Equal(t, c.payload, string(r.payload.Canonical(pkg.SignCanonical)), c.name)
+		assert.Equal(t, c.signature, r.signature, c.name)
+	}
+}
+
+func TestQuerySignatureUnchanged(t *testing.T) {
+	var got url.Values
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = r.URL.Query()
+		w.Write([]byte(`{"data":null}`))
+	}))
+	defer server.Close()
+	signer := &pkg.HmacSigner{Key: []byte("your secret key")}
+	service := pkg.NewService(server.URL, pkg.WithSigner(signer),
+		pkg.WithClock(func() time.Time { return time.Unix(1600000000, 0) }))
+
+	service.GetOrderCtx(context.Background(), pkg.OrderRequest{Id: 7})
+	assert.Equal(t, signer.Sign([]byte("id=7&recv_window=5000&timestamp=1600000000000")), got.Get("signature"))
+}
+
+func TestJSONBodySignature(t *testing.T) {
+	var body string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		raw, _ := ioutil.ReadAll(r.Body)
+		body = string(raw)
+		w.Write([]byte(`{"data":null}`))
+	}))
+	defer server.Close()
+	signer := &pkg.HmacSigner{Key: []byte("your secret key")}
+	service := pkg.NewService(server.URL, pkg.WithSigner(signer), pkg.WithBodyEncoding(pkg.BodyJSON),
+		pkg.WithClock(func() time.Time { return time.Unix(1600000000, 0) }))
+
+	service.CancelOrderCtx(context.Background(), pkg.CancelOrderRequest{Id: 7})
+	signed := `{"id":"7","recv_window":"5000","timestamp":"1600000000000"}`
+	assert.Equal(t, `{"id":"7","recv_window":"5000","signature":"`+signer.Sign([]byte(signed))+`","timestamp":"1600000000000"}`, body)
+}
@@ -0,0 +1,66 @@
+package tests
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/pem"
+	"testing"
+
+	"github.com/bmizerany/assert"
+	"github.com/xiangxian/exchange/pkg"
+)
+
+var signPayload = []byte("market=wonbtc&timestamp=1")
+
+func TestHmacSigners(t *testing.T) {
+	signer, err := pkg.NewSigner(pkg.SignerConfig{Scheme: pkg.SchemeHmacSHA512, Secret: "your secret key"})
+	assert.Equal(t, nil, err)
+	assert.Equal(t, "f731b945777cce133152d6686c1f07992259500cc4517826f19929f0ddcab4a8b4f97c3b6b42f1afc64bae1244ef6c0d95ae5f638ea398e49ac6b0fb25d0e0bb",
+		signer.Sign(signPayload))
+
+	signer, err = pkg.NewSigner(pkg.SignerConfig{Secret: "your secret key", Encoding: pkg.EncodingBase64})
+	assert.Equal(t, nil, err)
+	assert.Equal(t, "ag9PK16hK0TG2qCJqu7lq/UEplmzE33M42VaDqXea9Y=", signer.Sign(signPayload))
+
+	_, err = pkg.NewSigner(pkg.SignerConfig{Scheme: "md5"})
+	assert.NotEqual(t, nil, err)
+}
+
+func TestEd25519Signer(t *testing.T) {
+	key := ed25519.NewKeyFromSeed(make([]byte, ed25519.SeedSize))
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+	assert.Equal(t, nil, err)
+	keyPEM := string(pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}))
+
+	signer, err := pkg.NewSigner(pkg.SignerConfig{Scheme: pkg.SchemeEd25519, PrivateKeyPEM: keyPEM})
+	assert.Equal(t, nil, err)
+	sig, err := hex.DecodeString(signer.Sign(signPayload))
+	assert.Equal(t, nil, err)
+	assert.Equal(t, true, ed25519.Verify(key.Public().(ed25519.PublicKey), signPayload, sig))
+
+	// The key does not fit the scheme.
+	_, err = pkg.NewSigner(pkg.SignerConfig{Scheme: pkg.SchemeRSASHA256, PrivateKeyPEM: keyPEM})
+	assert.NotEqual(t, nil, err)
+}
+
+func TestRSASigner(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 1024)
+	assert.Equal(t, nil, err)
+	keyPEM := string(pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)}))
+
+	signer, err := pkg.NewSigner(pkg.SignerConfig{Scheme: pkg.SchemeRSASHA256, PrivateKeyPEM: keyPEM, Encoding: pkg.EncodingBase64})
+	assert.Equal(t, nil, err)
+	sig, err := base64.StdEncoding.DecodeString(signer.Sign(signPayload))
+	assert.Equal(t, nil, err)
+	digest := sha256.Sum256(signPayload)
+	assert.Equal(t, nil, rsa.VerifyPKCS1v15(&key.PublicKey, crypto.SHA256, digest[:], sig))
+
+	_, err = pkg.NewSigner(pkg.SignerConfig{Scheme: pkg.SchemeRSASHA256})
+	assert.NotEqual(t, nil, err)
+}
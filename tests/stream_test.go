@@ -0,0 +1,219 @@
+package tests
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/bmizerany/assert"
+	"github.com/gorilla/websocket"
+	"github.com/xiangxian/exchange/pkg"
+)
+
+// wsServer is an in-process stand-in for the exchange websocket endpoint.
+// serve is called for every connection with its index.
+type wsServer struct {
+	*httptest.Server
+	mu    sync.Mutex
+	conns int
+}
+
+func newWSServer(serve func(n int, conn *websocket.Conn)) *wsServer {
+	ws := &wsServer{}
+	upgrader := websocket.Upgrader{}
+	ws.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		ws.mu.Lock()
+		n := ws.conns
+		ws.conns++
+		ws.mu.Unlock()
+		serve(n, conn)
+	}))
+	return ws
+}
+
+func (ws *wsServer) wsURL() string {
+	return "ws" + strings.TrimPrefix(ws.URL, "http")
+}
+
+func readRequest(t *testing.T, conn *websocket.Conn) map[string]interface{} {
+	var req map[string]interface{}
+	if err := conn.ReadJSON(&req); err != nil {
+		t.Errorf("read request: %v", err)
+	}
+	return req
+}
+
+func TestMarketStream(t *testing.T) {
+	subscribed := make(chan []interface{}, 2)
+	server := newWSServer(func(n int, conn *websocket.Conn) {
+		req := readRequest(t, conn)
+		subscribed <- req["params"].([]interface{})
+		if n == 0 {
+			conn.WriteMessage(websocket.TextMessage, []byte(`{"stream":"wonbtc@depth","data":{"first_update_id":11,"last_update_id":12,"time":1,"bids":[["0.1","2"]],"asks":[["0.2","0"]]}}`))
+			conn.WriteMessage(websocket.TextMessage, []byte(`{"stream":"wonbtc@trade","data":{"id":7,"price":"0.15","qty":"3","time":2}}`))
+			// Drop the connection to force a reconnect.
+			return
+		}
+		conn.WriteMessage(websocket.TextMessage, []byte(`{"stream":"wonbtc@ticker","data":{"price":"0.16","time":3}}`))
+		conn.ReadMessage()
+	})
+	defer server.Close()
+
+	var mu sync.Mutex
+	var depth []pkg.DepthUpdate
+	var trades []pkg.TradeEvent
+	tickers := make(chan pkg.TickerEvent, 1)
+	disconnects := 0
+	stream := pkg.NewMarketStream(server.wsURL(), pkg.MarketHandler{
+		OnDepth:      func(u pkg.DepthUpdate) { mu.Lock(); depth = append(depth, u); mu.Unlock() },
+		OnTrade:      func(e pkg.TradeEvent) { mu.Lock(); trades = append(trades, e); mu.Unlock() },
+		OnTicker:     func(e pkg.TickerEvent) { tickers <- e },
+		OnDisconnect: func(error) { mu.Lock(); disconnects++; mu.Unlock() },
+	}, pkg.WithReconnectDelay(time.Millisecond, 10*time.Millisecond))
+	assert.Equal(t, nil, stream.Subscribe([]string{"wonbtc"}, pkg.DepthChannel, pkg.TradeChannel, pkg.TickerChannel))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() { done <- stream.Run(ctx) }()
+
+	var ticker pkg.TickerEvent
+	select {
+	case ticker = <-tickers:
+	case <-time.After(2 * time.Second):
+		t.Fatal("no ticker event after reconnect")
+	}
+	cancel()
+	assert.Equal(t, context.Canceled, <-done)
+
+	assert.Equal(t, 3, len(<-subscribed))
+	assert.Equal(t, 3, len(<-subscribed))
+
+	mu.Lock()
+	defer mu.Unlock()
+	assert.Equal(t, 1, len(depth))
+	assert.Equal(t, int64(12), depth[0].LastUpdateId)
+	assert.Equal(t, level("0.1", "2"), depth[0].Bids[0])
+	assert.Equal(t, 1, len(trades))
+	assert.Equal(t, "wonbtc", trades[0].Market)
+	assert.Equal(t, int64(7), trades[0].Id)
+	assert.Equal(t, "0.16", ticker.Price.String())
+	assert.Equal(t, true, disconnects >= 1)
+}
+
+func TestMarketStreamSubscribeWhileConnected(t *testing.T) {
+	requests := make(chan map[string]interface{}, 2)
+	server := newWSServer(func(n int, conn *websocket.Conn) {
+		for {
+			var req map[string]interface{}
+			if err := conn.ReadJSON(&req); err != nil {
+				return
+			}
+			requests <- req
+		}
+	})
+	defer server.Close()
+
+	stream := pkg.NewMarketStream(server.wsURL(), pkg.MarketHandler{})
+	assert.Equal(t, nil, stream.Subscribe([]string{"wonbtc"}, pkg.TickerChannel))
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go stream.Run(ctx)
+
+	first := <-requests
+	assert.Equal(t, "subscribe", first["method"])
+
+	// Subscribe may race with the connection being established; retry until
+	// the request is sent on the open connection.
+	deadline := time.Now().Add(2 * time.Second)
+	var second map[string]interface{}
+	for second == nil && time.Now().Before(deadline) {
+		stream.Unsubscribe([]string{"wonbtc"}, pkg.TickerChannel)
+		select {
+		case second = <-requests:
+		case <-time.After(10 * time.Millisecond):
+		}
+	}
+	raw, _ := json.Marshal(second)
+	assert.Equal(t, `{"method":"unsubscribe","params":["wonbtc@ticker"]}`, string(raw))
+}
+
+func TestMarketStreamDropsSilentConnection(t *testing.T) {
+	pinged := make(chan bool, 1)
+	hang := make(chan struct{})
+	defer close(hang)
+	server := newWSServer(func(n int, conn *websocket.Conn) {
+		conn.ReadMessage()
+		if n == 0 {
+			// Answer nothing, pings included, as a half-open connection.
+			conn.SetPingHandler(func(string) error {
+				select {
+				case pinged <- true:
+				default:
+				}
+				return nil
+			})
+			go conn.ReadMessage()
+			<-hang
+			return
+		}
+		conn.ReadMessage()
+	})
+	defer server.Close()
+
+	disconnects := make(chan error, 1)
+	stream := pkg.NewMarketStream(server.wsURL(), pkg.MarketHandler{
+		OnDisconnect: func(err error) { disconnects <- err },
+	}, pkg.WithHeartbeat(10*time.Millisecond, 50*time.Millisecond), pkg.WithReconnectDelay(time.Millisecond, 10*time.Millisecond))
+	assert.Equal(t, nil, stream.Subscribe([]string{"wonbtc"}, pkg.TickerChannel))
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go stream.Run(ctx)
+
+	select {
+	case err := <-disconnects:
+		assert.NotEqual(t, nil, err)
+	case <-time.After(2 * time.Second):
+		t.Fatal("silent connection was not dropped")
+	}
+	assert.Equal(t, true, <-pinged)
+}
+
+func TestUserStreamLoginTimeout(t *testing.T) {
+	hang := make(chan struct{})
+	defer close(hang)
+	server := newWSServer(func(n int, conn *websocket.Conn) {
+		conn.ReadMessage()
+		<-hang
+	})
+	defer server.Close()
+
+	disconnects := make(chan error, 1)
+	handler := pkg.UserHandler{OnDisconnect: func(err error) {
+		select {
+		case disconnects <- err:
+		default:
+		}
+	}}
+	stream := pkg.NewUserStream(server.wsURL(), "api key", &pkg.HmacSigner{Key: []byte("your secret key")}, handler,
+		pkg.WithHeartbeat(10*time.Millisecond, 50*time.Millisecond))
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go stream.Run(ctx)
+
+	select {
+	case err := <-disconnects:
+		assert.Equal(t, true, strings.Contains(err.Error(), "login failed"))
+	case <-time.After(2 * time.Second):
+		t.Fatal("unanswered login did not time out")
+	}
+}
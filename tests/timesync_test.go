@@ -0,0 +1,135 @@
+package tests
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/bmizerany/assert"
+	"github.com/xiangxian/exchange/pkg"
+)
+
+// newClockServer serves api/v1/time from a clock running ahead by skew and
+// records the timestamp of every signed request.
+func newClockServer(skew time.Duration) (*httptest.Server, func() []int64) {
+	var mu sync.Mutex
+	var stamps []int64
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/api/v1/time" {
+			now := time.Now().Add(skew).UnixNano() / int64(time.Millisecond)
+			fmt.Fprintf(w, `{"data":{"time":%d}}`, now)
+			return
+		}
+		ts, _ := strconv.ParseInt(r.URL.Query().Get("timestamp"), 10, 64)
+		mu.Lock()
+		stamps = append(stamps, ts)
+		mu.Unlock()
+		w.Write([]byte(`{"data":{"id":1495}}`))
+	}))
+	return server, func() []int64 {
+		mu.Lock()
+		defer mu.Unlock()
+		return append([]int64(nil), stamps...)
+	}
+}
+
+func TestSyncTimeCorrectsTimestamp(t *testing.T) {
+	skew := 10 * time.Second
+	server, stamps := newClockServer(skew)
+	defer server.Close()
+
+	service := pkg.NewService(server.URL, pkg.WithSigner(&pkg.HmacSigner{Key: []byte("your secret key")}))
+	assert.Equal(t, nil, service.SyncTime(context.Background()))
+	offset := service.ClockOffset()
+	assert.Equal(t, true, offset > skew-time.Second && offset < skew+time.Second)
+
+	_, err := service.GetOrder(pkg.OrderRequest{Id: 1495})
+	assert.Equal(t, nil, err)
+	sent := time.Unix(0, stamps()[0]*int64(time.Millisecond))
+	drift := sent.Sub(time.Now().Add(skew))
+	assert.Equal(t, true, drift > -time.Second && drift < time.Second)
+}
+
+func TestTimeSyncLoop(t *testing.T) {
+	server, _ := newClockServer(-5 * time.Second)
+	defer server.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	service := pkg.NewService(server.URL, pkg.WithContext(ctx), pkg.WithTimeSync(10*time.Millisecond))
+
+	deadline := time.Now().Add(time.Second)
+	for service.ClockOffset() == 0 && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+	}
+	assert.Equal(t, true, service.ClockOffset() < -4*time.Second)
+}
+
+func TestTimeSyncStopsOnClose(t *testing.T) {
+	var mu sync.Mutex
+	syncs := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		syncs++
+		mu.Unlock()
+		fmt.Fprintf(w, `{"data":{"time":%d}}`, time.Now().UnixNano()/int64(time.Millisecond))
+	}))
+	defer server.Close()
+	count := func() int {
+		mu.Lock()
+		defer mu.Unlock()
+		return syncs
+	}
+
+	service := pkg.NewService(server.URL, pkg.WithTimeSync(5*time.Millisecond))
+	deadline := time.Now().Add(time.Second)
+	for count() < 2 && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+	}
+	assert.Equal(t, nil, service.Close())
+	time.Sleep(20 * time.Millisecond)
+	stopped := count()
+	time.Sleep(50 * time.Millisecond)
+	assert.Equal(t, stopped, count())
+}
+
+func TestDefaultRecvWindow(t *testing.T) {
+	var got *http.Request
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = r
+		w.Write([]byte(`{"data":"success"}`))
+	}))
+	defer server.Close()
+
+	signer := &pkg.HmacSigner{Key: []byte("your secret key")}
+	err := pkg.NewService(server.URL, pkg.WithSigner(signer)).CancelOrder(pkg.CancelOrderRequest{Id: 1501})
+	assert.Equal(t, nil, err)
+	assert.Equal(t, strconv.Itoa(pkg.DefaultRecvWindow), got.URL.Query().Get("recv_window"))
+
+	err = pkg.NewService(server.URL, pkg.WithSigner(signer), pkg.WithRecvWindow(-1)).CancelOrder(pkg.CancelOrderRequest{Id: 1501})
+	assert.Equal(t, nil, err)
+	_, ok := got.URL.Query()["recv_window"]
+	assert.Equal(t, false, ok)
+}
+
+func TestMyTradesStamped(t *testing.T) {
+	var got *http.Request
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = r
+		w.Write([]byte(`{"data":[]}`))
+	}))
+	defer server.Close()
+
+	now := time.Unix(1600000000, 0)
+	service := pkg.NewService(server.URL, pkg.WithSigner(&pkg.HmacSigner{Key: []byte("your secret key")}),
+		pkg.WithClock(func() time.Time { return now }))
+	_, err := service.MyTrades(pkg.TradeRequest{Market: "wonbtc"})
+	assert.Equal(t, nil, err)
+	assert.Equal(t, "1600000000000", got.URL.Query().Get("timestamp"))
+	assert.Equal(t, strconv.Itoa(pkg.DefaultRecvWindow), got.URL.Query().Get("recv_window"))
+}
@@ -0,0 +1,70 @@
+package tests
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/bmizerany/assert"
+	"github.com/xiangxian/exchange/pkg"
+)
+
+func TestOrderTracker(t *testing.T) {
+	order := openOrder(1, "wonbtc", pkg.SideBuy)
+	order.Volume, order.RemainingVolume = pkg.MustDecimal("10"), pkg.MustDecimal("10")
+	cancelled := openOrder(2, "wonbtc", pkg.SideSell)
+	cancelled.State = pkg.StateCancel
+	book, server := newFakeBook(order, cancelled)
+	defer server.Close()
+
+	var mu sync.Mutex
+	var events []pkg.OrderEvent
+	service := pkg.NewService(server.URL, pkg.WithSigner(&pkg.HmacSigner{Key: []byte("your secret key")}))
+	tracker := pkg.NewOrderTracker(service, time.Hour, func(e pkg.OrderEvent) {
+		mu.Lock()
+		events = append(events, e)
+		mu.Unlock()
+	})
+	tracker.Watch(1, 2)
+	ctx := context.Background()
+
+	assert.Equal(t, nil, tracker.Poll(ctx))
+	book.fill(1, "4")
+	assert.Equal(t, nil, tracker.Poll(ctx))
+	assert.Equal(t, nil, tracker.Poll(ctx))
+	book.fill(1, "6")
+	assert.Equal(t, nil, tracker.Poll(ctx))
+
+	done, err := tracker.Wait(ctx, 1)
+	assert.Equal(t, nil, err)
+	assert.Equal(t, pkg.StateDone, done.State)
+
+	mu.Lock()
+	defer mu.Unlock()
+	byOrder := map[int64][]pkg.OrderEvent{}
+	for _, e := range events {
+		byOrder[e.Order.Id] = append(byOrder[e.Order.Id], e)
+	}
+	filled := byOrder[1]
+	assert.Equal(t, 3, len(filled))
+	assert.Equal(t, pkg.OrderAccepted, filled[0].Type)
+	assert.Equal(t, pkg.OrderPartiallyFilled, filled[1].Type)
+	assert.Equal(t, "4", filled[1].Delta.String())
+	assert.Equal(t, 1, len(filled[1].Trades))
+	assert.Equal(t, true, filled[1].Reconciled)
+	assert.Equal(t, pkg.OrderFilled, filled[2].Type)
+	assert.Equal(t, "6", filled[2].Delta.String())
+	assert.Equal(t, true, filled[2].Reconciled)
+
+	assert.Equal(t, 2, len(byOrder[2]))
+	assert.Equal(t, pkg.OrderCancelled, byOrder[2][1].Type)
+}
+
+func TestOrderTrackerWaitTimesOut(t *testing.T) {
+	tracker := pkg.NewOrderTracker(nil, time.Hour, nil)
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	_, err := tracker.Wait(ctx, 1)
+	assert.Equal(t, context.DeadlineExceeded, err)
+}
@@ -0,0 +1,271 @@
+package tests
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/bmizerany/assert"
+	"github.com/gorilla/websocket"
+	"github.com/xiangxian/exchange/pkg"
+)
+
+type userEvents struct {
+	mu       sync.Mutex
+	orders   []pkg.Order
+	trades   []pkg.MyTradeEvent
+	balances []pkg.CurrencyAccount
+}
+
+func (e *userEvents) handler() pkg.UserHandler {
+	return pkg.UserHandler{
+		OnOrder:   func(o pkg.Order) { e.mu.Lock(); e.orders = append(e.orders, o); e.mu.Unlock() },
+		OnTrade:   func(t pkg.MyTradeEvent) { e.mu.Lock(); e.trades = append(e.trades, t); e.mu.Unlock() },
+		OnBalance: func(a pkg.CurrencyAccount) { e.mu.Lock(); e.balances = append(e.balances, a); e.mu.Unlock() },
+	}
+}
+
+func (e *userEvents) count() int {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return len(e.orders) + len(e.trades) + len(e.balances)
+}
+
+func waitFor(t *testing.T, cond func() bool) {
+	deadline := time.Now().Add(2 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatal("condition not met in time")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestUserStream(t *testing.T) {
+	signer := &pkg.HmacSigner{Key: []byte("your secret key")}
+	login := make(chan []interface{}, 1)
+	server := newWSServer(func(n int, conn *websocket.Conn) {
+		req := readRequest(t, conn)
+		login <- req["params"].([]interface{})
+		conn.WriteJSON(map[string]string{"result": "ok"})
+		conn.WriteMessage(websocket.TextMessage, []byte(`{"stream":"orders","data":{"id":1495,"side":"sell","state":"done","market":"wonbtc","remaining_volume":"0"}}`))
+		conn.WriteMessage(websocket.TextMessage, []byte(`{"stream":"trades","data":{"market":"wonbtc","id":9,"order_id":1495,"price":"0.1","side":"sell","qty":"10","time":1}}`))
+		conn.WriteMessage(websocket.TextMessage, []byte(`{"stream":"balances","data":{"currency":"won","balance":"90","locked":"0","limits":{"minimal_trade_fee":"0.1"}}}`))
+		conn.ReadMessage()
+	})
+	defer server.Close()
+
+	events := &userEvents{}
+	stream := pkg.NewUserStream(server.wsURL(), "api key", signer, events.handler())
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go stream.Run(ctx)
+
+	params := <-login
+	assert.Equal(t, "api key", params[0])
+	payload := url.Values{"api_key": {"api key"}, "timestamp": {params[1].(string)}}.Encode()
+	assert.Equal(t, signer.Sign([]byte(payload)), params[2])
+
+	waitFor(t, func() bool { return events.count() == 3 })
+	events.mu.Lock()
+	defer events.mu.Unlock()
+	assert.Equal(t, pkg.StateDone, events.orders[0].State)
+	assert.Equal(t, int64(1495), events.trades[0].OrderId)
+	assert.Equal(t, "wonbtc", events.trades[0].Market)
+	assert.Equal(t, "90", events.balances[0].Balance.String())
+	assert.Equal(t, "0.1", events.balances[0].Limits.MinimalTradeFee.String())
+}
+
+func TestUserStreamLoginClock(t *testing.T) {
+	login := make(chan []interface{}, 1)
+	server := newWSServer(func(n int, conn *websocket.Conn) {
+		login <- readRequest(t, conn)["params"].([]interface{})
+		conn.WriteJSON(map[string]string{"result": "ok"})
+		conn.ReadMessage()
+	})
+	defer server.Close()
+
+	skewed := time.Unix(1600000000, 0)
+	stream := pkg.NewUserStream(server.wsURL(), "api key", &pkg.HmacSigner{Key: []byte("your secret key")}, pkg.UserHandler{},
+		pkg.WithStreamClock(func() time.Time { return skewed }))
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go stream.Run(ctx)
+
+	params := <-login
+	assert.Equal(t, "1600000000000", params[1])
+}
+
+func TestUserStreamLoginRejectedBacksOff(t *testing.T) {
+	server := newWSServer(func(n int, conn *websocket.Conn) {
+		// The last connection may be closed mid-login as the test ends.
+		conn.ReadMessage()
+		conn.WriteJSON(map[string]string{"error": "invalid_signature", "error_description": "signature mismatch"})
+		conn.ReadMessage()
+	})
+	defer server.Close()
+
+	var mu sync.Mutex
+	var errs []error
+	handler := pkg.UserHandler{OnDisconnect: func(err error) { mu.Lock(); errs = append(errs, err); mu.Unlock() }}
+	stream := pkg.NewUserStream(server.wsURL(), "api key", &pkg.HmacSigner{Key: []byte("your secret key")}, handler,
+		pkg.WithReconnectDelay(10*time.Millisecond, time.Second))
+	ctx, cancel := context.WithTimeout(context.Background(), 300*time.Millisecond)
+	defer cancel()
+	stream.Run(ctx)
+
+	mu.Lock()
+	defer mu.Unlock()
+	assert.Equal(t, true, pkg.IsInvalidSignature(errs[0]))
+	// 10, 20, 40, 80 and 160ms apart; without backoff it would be ~30.
+	assert.Equal(t, true, len(errs) <= 7)
+}
+
+// restAccount is a REST stand-in serving a single order, trade list and
+// balance that tests can change between polls.
+type restAccount struct {
+	mu      sync.Mutex
+	state   string
+	trades  string
+	balance string
+	polls   int
+}
+
+func (ra *restAccount) set(state, trades, balance string) {
+	ra.mu.Lock()
+	ra.state, ra.trades, ra.balance = state, trades, balance
+	ra.mu.Unlock()
+}
+
+// pollCount returns how many polls reached the account endpoint, the last
+// one of a poll.
+func (ra *restAccount) pollCount() int {
+	ra.mu.Lock()
+	defer ra.mu.Unlock()
+	return ra.polls
+}
+
+func (ra *restAccount) serve(w http.ResponseWriter, r *http.Request) {
+	ra.mu.Lock()
+	defer ra.mu.Unlock()
+	order := fmt.Sprintf(`{"id":1495,"market":"wonbtc","state":"%s"}`, ra.state)
+	switch r.URL.Path {
+	case "/api/v1/orders":
+		if ra.state == "wait" {
+			fmt.Fprintf(w, `{"data":[%s]}`, order)
+		} else {
+			w.Write([]byte(`{"data":[]}`))
+		}
+	case "/api/v1/order":
+		fmt.Fprintf(w, `{"data":%s}`, order)
+	case "/api/v1/trades/my":
+		fmt.Fprintf(w, `{"data":[%s]}`, ra.trades)
+	case "/api/v1/account":
+		ra.polls++
+		fmt.Fprintf(w, `{"data":{"accounts":[{"currency":"won","balance":"%s"}]}}`, ra.balance)
+	}
+}
+
+func TestUserPoller(t *testing.T) {
+	account := &restAccount{}
+	account.set("wait", `{"id":8,"order_id":1}`, "100")
+	server := httptest.NewServer(http.HandlerFunc(account.serve))
+	defer server.Close()
+
+	service := pkg.NewService(server.URL, pkg.WithSigner(&pkg.HmacSigner{Key: []byte("your secret key")}))
+	events := &userEvents{}
+	poller := pkg.NewUserPoller(service, []string{"wonbtc"}, time.Second, events.handler())
+
+	assert.Equal(t, nil, poller.Poll(context.Background()))
+	assert.Equal(t, 0, events.count())
+
+	account.set("done", `{"id":8,"order_id":1},{"id":9,"order_id":1495,"qty":"10"}`, "90")
+	assert.Equal(t, nil, poller.Poll(context.Background()))
+	assert.Equal(t, 1, len(events.orders))
+	assert.Equal(t, pkg.StateDone, events.orders[0].State)
+	assert.Equal(t, 1, len(events.trades))
+	assert.Equal(t, int64(9), events.trades[0].Id)
+	assert.Equal(t, 1, len(events.balances))
+	assert.Equal(t, "90", events.balances[0].Balance.String())
+
+	assert.Equal(t, nil, poller.Poll(context.Background()))
+	assert.Equal(t, 3, events.count())
+}
+
+// newDroppingUserStream returns a user stream whose first connection logs in,
+// sends the messages received on push and closes once push is closed. Every
+// later connection fails.
+func newDroppingUserStream(t *testing.T, rest *httptest.Server, events *userEvents) (*wsServer, chan string, *pkg.UserStream) {
+	push := make(chan string, 1)
+	server := newWSServer(func(n int, conn *websocket.Conn) {
+		if n > 0 {
+			return
+		}
+		readRequest(t, conn)
+		conn.WriteJSON(map[string]string{"result": "ok"})
+		for msg := range push {
+			conn.WriteMessage(websocket.TextMessage, []byte(msg))
+		}
+	})
+	service := pkg.NewService(rest.URL, pkg.WithSigner(&pkg.HmacSigner{Key: []byte("your secret key")}))
+	stream := pkg.NewUserStream(server.wsURL(), "api key", &pkg.HmacSigner{Key: []byte("your secret key")},
+		events.handler(), pkg.WithReconnectDelay(time.Millisecond, 10*time.Millisecond))
+	stream.SetFallback(pkg.NewUserPoller(service, []string{"wonbtc"}, 10*time.Millisecond, events.handler()))
+	return server, push, stream
+}
+
+func TestUserStreamFallback(t *testing.T) {
+	account := &restAccount{}
+	account.set("wait", `{"id":8}`, "100")
+	rest := httptest.NewServer(http.HandlerFunc(account.serve))
+	defer rest.Close()
+	events := &userEvents{}
+	server, push, stream := newDroppingUserStream(t, rest, events)
+	defer server.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go stream.Run(ctx)
+
+	// The poll made on login records the open order.
+	waitFor(t, func() bool { return account.pollCount() == 1 })
+	// The order is cancelled before the stream drops and before the first
+	// fallback poll.
+	account.set("cancel", `{"id":8}`, "100")
+	close(push)
+	waitFor(t, func() bool { return events.count() == 1 })
+	events.mu.Lock()
+	assert.Equal(t, pkg.StateCancel, events.orders[0].State)
+	events.mu.Unlock()
+}
+
+func TestUserStreamFallbackSkipsDelivered(t *testing.T) {
+	account := &restAccount{}
+	account.set("wait", `{"id":8}`, "100")
+	rest := httptest.NewServer(http.HandlerFunc(account.serve))
+	defer rest.Close()
+	events := &userEvents{}
+	server, push, stream := newDroppingUserStream(t, rest, events)
+	defer server.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go stream.Run(ctx)
+
+	waitFor(t, func() bool { return account.pollCount() == 1 })
+	account.set("cancel", `{"id":8},{"id":9,"order_id":1495}`, "90")
+	push <- `{"stream":"orders","data":{"id":1495,"market":"wonbtc","state":"cancel"}}`
+	push <- `{"stream":"trades","data":{"market":"wonbtc","id":9,"order_id":1495}}`
+	push <- `{"stream":"balances","data":{"currency":"won","balance":"90"}}`
+	waitFor(t, func() bool { return events.count() == 3 })
+	close(push)
+
+	// The fallback polls find nothing the stream did not deliver.
+	waitFor(t, func() bool { return account.pollCount() >= 4 })
+	assert.Equal(t, 3, events.count())
+}
@@ -0,0 +1,131 @@
+package tests
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"github.com/bmizerany/assert"
+	"github.com/xiangxian/exchange/pkg"
+)
+
+// newTradingServer serves exchange info and an account holding 0.01 btc and
+// 500 won, and records the query of every order created.
+func newTradingServer() (*httptest.Server, func() []url.Values) {
+	var mu sync.Mutex
+	var orders []url.Values
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/api/v1/exchange_info":
+			w.Write([]byte(exchangeInfoBody))
+		case "/api/v1/account":
+			w.Write([]byte(`{"data":{"accounts":[{"currency":"btc","balance":"0.01"},{"currency":"won","balance":"500"}]}}`))
+		default:
+			mu.Lock()
+			orders = append(orders, r.URL.Query())
+			mu.Unlock()
+			w.Write([]byte(`{"data":{"id":1}}`))
+		}
+	}))
+	return server, func() []url.Values {
+		mu.Lock()
+		defer mu.Unlock()
+		return orders
+	}
+}
+
+func TestOrderValidationNormalizes(t *testing.T) {
+	server, orders := newTradingServer()
+	defer server.Close()
+
+	service := pkg.NewService(server.URL,
+		pkg.WithSigner(&pkg.HmacSigner{Key: []byte("your secret key")}),
+		pkg.WithOrderValidation(pkg.ValidationConfig{PriceRounding: pkg.RoundUp, CheckBalance: true}))
+	_, err := service.CreateOrder(pkg.CreateOrderRequest{Market: "wonbtc", Side: "sell", OrdType: "limit",
+		Price: pkg.MustDecimal("0.000012345"), Volume: pkg.MustDecimal("20.7")})
+	assert.Equal(t, nil, err)
+	assert.Equal(t, 1, len(orders()))
+	assert.Equal(t, "0.00001235", orders()[0]["price"][0])
+	assert.Equal(t, "20", orders()[0]["volume"][0])
+}
+
+func TestOrderValidationRejects(t *testing.T) {
+	server, orders := newTradingServer()
+	defer server.Close()
+
+	ctx := context.Background()
+	validator := pkg.NewOrderValidator(pkg.NewService(server.URL, pkg.WithSigner(&pkg.HmacSigner{Key: []byte("your secret key")})), pkg.ValidationConfig{CheckBalance: true})
+	order := pkg.CreateOrderRequest{Market: "wonbtc", Side: "buy", OrdType: "limit",
+		Price: pkg.MustDecimal("0.00001"), Volume: pkg.MustDecimal("100")}
+	_, err := validator.Validate(ctx, order)
+	assert.Equal(t, nil, err)
+
+	cases := map[string]func(o *pkg.CreateOrderRequest){
+		"side":     func(o *pkg.CreateOrderRequest) { o.Side = "short" },
+		"ord_type": func(o *pkg.CreateOrderRequest) { o.OrdType = "iceberg" },
+		"market":   func(o *pkg.CreateOrderRequest) { o.Market = "woneth" },
+		"price":    func(o *pkg.CreateOrderRequest) { o.Price = pkg.MustDecimal("0.000000001") },
+		"volume":   func(o *pkg.CreateOrderRequest) { o.Volume = pkg.MustDecimal("9.9") },
+	}
+	for field, change := range cases {
+		o := order
+		change(&o)
+		_, err := validator.Validate(ctx, o)
+		assert.Equal(t, true, err != nil && err.(*pkg.ValidationError).Field == field, field)
+		assert.Equal(t, true, errors.Is(err, pkg.ErrInvalidOrder))
+	}
+
+	// Below min notional: 0.00001 * 5 < 0.0001.
+	o := order
+	o.Volume = pkg.MustDecimal("10")
+	o.Price = pkg.MustDecimal("0.000001")
+	_, err = validator.Validate(ctx, o)
+	assert.Equal(t, true, errors.Is(err, pkg.ErrInvalidOrder))
+
+	o = order
+	o.Market = "nope"
+	_, err = validator.Validate(ctx, o)
+	assert.Equal(t, true, pkg.IsUnknownMarket(err))
+
+	// 0.00001 * 2000 = 0.02 btc, more than the 0.01 held.
+	o = order
+	o.Volume = pkg.MustDecimal("2000")
+	_, err = validator.Validate(ctx, o)
+	assert.Equal(t, true, pkg.IsInsufficientFunds(err))
+	assert.Equal(t, 0, len(orders()))
+}
+
+func TestCreateOrdersChecksBalanceTogether(t *testing.T) {
+	var accounts, creates int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/api/v1/exchange_info":
+			w.Write([]byte(exchangeInfoBody))
+		case "/api/v1/account":
+			atomic.AddInt32(&accounts, 1)
+			w.Write([]byte(`{"data":{"accounts":[{"currency":"btc","balance":"0.01"}]}}`))
+		default:
+			atomic.AddInt32(&creates, 1)
+			w.Write([]byte(`{"data":{"id":1}}`))
+		}
+	}))
+	defer server.Close()
+
+	service := pkg.NewService(server.URL,
+		pkg.WithSigner(&pkg.HmacSigner{Key: []byte("your secret key")}),
+		pkg.WithOrderValidation(pkg.ValidationConfig{CheckBalance: true}))
+	// Each buy costs 0.004 btc: the first two fit the 0.01 held, the third
+	// does not.
+	order := pkg.LimitOrder("wonbtc", pkg.SideBuy, pkg.MustDecimal("0.00001"), pkg.MustDecimal("400"))
+	results := service.CreateOrders([]pkg.CreateOrderRequest{order, order, order})
+	assert.Equal(t, nil, results[0].Err)
+	assert.Equal(t, nil, results[1].Err)
+	assert.Equal(t, true, pkg.IsInsufficientFunds(results[2].Err))
+	assert.Equal(t, int32(1), atomic.LoadInt32(&accounts))
+	assert.Equal(t, int32(2), atomic.LoadInt32(&creates))
+}
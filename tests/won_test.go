@@ -76,8 +76,8 @@ func TestCreateOrder(t *testing.T) {
 	r, err := won.CreateOrder(pkg.CreateOrderRequest{
 		Market:     "topwon",
 		Side:       "sell",
-		Price:      "5.0001",
-		Volume:     "10",
+		Price:      pkg.MustDecimal("5.0001"),
+		Volume:     pkg.MustDecimal("10"),
 		OrdType:    "limit",
 		Timestamp:  int64(time.Now().Unix() * 1000),
 		RecvWindow: 5000})
@@ -87,7 +87,7 @@ func TestCreateOrder(t *testing.T) {
 
 func TestCancelOrder(t *testing.T) {
 	won := initWon()
-	err := won.CancelOrder(pkg.CancelOrderRequest{Id: 1501, Timestamp: int64(time.Now().Unix() * 100), RecvWindow: 5000})
+	err := won.CancelOrder(pkg.CancelOrderRequest{Id: 1501})
 
 	assert.Equal(t, nil, err)
 }
@@ -1,6 +1,7 @@
 package exchange
 
 import (
+	"context"
 	"github.com/xiangxian/exchange/pkg"
 	"time"
 )
@@ -16,6 +17,40 @@ type Won interface {
 	GetOrders(pkg.OrdersRequest) ([]*pkg.Order, error)
 	GetOrder(pkg.OrderRequest) (*pkg.Order, error)
 	CancelOrder(pkg.CancelOrderRequest) error
+	CreateOrRecoverOrder(pkg.CreateOrderRequest) (*pkg.Order, error)
+	CreateOrders([]pkg.CreateOrderRequest) []pkg.OrderResult
+	CancelOrders(ids []int64) []pkg.CancelResult
+	CancelAll(market string, side pkg.Side) (*pkg.CancelReport, error)
+	CancelWhere(match func(*pkg.Order) bool) (*pkg.CancelReport, error)
+	AmendOrder(pkg.AmendOrderRequest) (*pkg.AmendResult, error)
+
+	TimeCtx(context.Context) (time.Time, error)
+	DepthCtx(context.Context, pkg.DepthRequest) (*pkg.DepthResult, error)
+	RecentTradesCtx(context.Context, pkg.TradeRequest) ([]*pkg.RecentTrade, error)
+	MyTradesCtx(context.Context, pkg.TradeRequest) ([]*pkg.MyTrade, error)
+	AccountCtx(context.Context, pkg.AccountRequest) (*pkg.Account, error)
+	TickerPriceCtx(context.Context, pkg.TickerPriceRequest) (*pkg.TickerPrice, error)
+	CreateOrderCtx(context.Context, pkg.CreateOrderRequest) (*pkg.Order, error)
+	GetOrdersCtx(context.Context, pkg.OrdersRequest) ([]*pkg.Order, error)
+	GetOrderCtx(context.Context, pkg.OrderRequest) (*pkg.Order, error)
+	CancelOrderCtx(context.Context, pkg.CancelOrderRequest) error
+	CreateOrRecoverOrderCtx(context.Context, pkg.CreateOrderRequest) (*pkg.Order, error)
+	CreateOrdersCtx(context.Context, []pkg.CreateOrderRequest) []pkg.OrderResult
+	CancelOrdersCtx(ctx context.Context, ids []int64) []pkg.CancelResult
+	CancelAllCtx(ctx context.Context, market string, side pkg.Side) (*pkg.CancelReport, error)
+	CancelWhereCtx(ctx context.Context, match func(*pkg.Order) bool) (*pkg.CancelReport, error)
+	AmendOrderCtx(context.Context, pkg.AmendOrderRequest) (*pkg.AmendResult, error)
+
+	SyncTime(context.Context) error
+	ClockOffset() time.Duration
+	Close() error
+
+	RateLimitBudget(bucket string) (pkg.Budget, bool)
+
+	ExchangeInfo() (*pkg.ExchangeInfo, error)
+	ExchangeInfoCtx(context.Context) (*pkg.ExchangeInfo, error)
+	MarketInfo(market string) (*pkg.MarketInfo, error)
+	MarketInfoCtx(ctx context.Context, market string) (*pkg.MarketInfo, error)
 }
 
 type won struct {
@@ -58,3 +93,97 @@ func (w *won) GetOrder(or pkg.OrderRequest) (*pkg.Order, error) {
 func (w *won) CancelOrder(cor pkg.CancelOrderRequest) error {
 	return w.Service.CancelOrder(cor)
 }
+func (w *won) CreateOrRecoverOrder(cor pkg.CreateOrderRequest) (*pkg.Order, error) {
+	return w.Service.CreateOrRecoverOrder(cor)
+}
+func (w *won) CreateOrders(orders []pkg.CreateOrderRequest) []pkg.OrderResult {
+	return w.Service.CreateOrders(orders)
+}
+func (w *won) CancelOrders(ids []int64) []pkg.CancelResult {
+	return w.Service.CancelOrders(ids)
+}
+func (w *won) CancelAll(market string, side pkg.Side) (*pkg.CancelReport, error) {
+	return w.Service.CancelAll(market, side)
+}
+func (w *won) CancelWhere(match func(*pkg.Order) bool) (*pkg.CancelReport, error) {
+	return w.Service.CancelWhere(match)
+}
+func (w *won) AmendOrder(aor pkg.AmendOrderRequest) (*pkg.AmendResult, error) {
+	return w.Service.AmendOrder(aor)
+}
+
+func (w *won) TimeCtx(ctx context.Context) (time.Time, error) {
+	return w.Service.TimeCtx(ctx)
+}
+func (w *won) DepthCtx(ctx context.Context, dr pkg.DepthRequest) (*pkg.DepthResult, error) {
+	return w.Service.DepthCtx(ctx, dr)
+}
+func (w *won) RecentTradesCtx(ctx context.Context, tr pkg.TradeRequest) ([]*pkg.RecentTrade, error) {
+	return w.Service.RecentTradesCtx(ctx, tr)
+}
+func (w *won) MyTradesCtx(ctx context.Context, tr pkg.TradeRequest) ([]*pkg.MyTrade, error) {
+	return w.Service.MyTradesCtx(ctx, tr)
+}
+func (w *won) AccountCtx(ctx context.Context, ar pkg.AccountRequest) (*pkg.Account, error) {
+	return w.Service.AccountCtx(ctx, ar)
+}
+func (w *won) TickerPriceCtx(ctx context.Context, tpr pkg.TickerPriceRequest) (*pkg.TickerPrice, error) {
+	return w.Service.TickerPriceCtx(ctx, tpr)
+}
+func (w *won) CreateOrderCtx(ctx context.Context, cor pkg.CreateOrderRequest) (*pkg.Order, error) {
+	return w.Service.CreateOrderCtx(ctx, cor)
+}
+func (w *won) GetOrdersCtx(ctx context.Context, osr pkg.OrdersRequest) ([]*pkg.Order, error) {
+	return w.Service.GetOrdersCtx(ctx, osr)
+}
+func (w *won) GetOrderCtx(ctx context.Context, or pkg.OrderRequest) (*pkg.Order, error) {
+	return w.Service.GetOrderCtx(ctx, or)
+}
+func (w *won) CancelOrderCtx(ctx context.Context, cor pkg.CancelOrderRequest) error {
+	return w.Service.CancelOrderCtx(ctx, cor)
+}
+func (w *won) CreateOrRecoverOrderCtx(ctx context.Context, cor pkg.CreateOrderRequest) (*pkg.Order, error) {
+	return w.Service.CreateOrRecoverOrderCtx(ctx, cor)
+}
+func (w *won) CreateOrdersCtx(ctx context.Context, orders []pkg.CreateOrderRequest) []pkg.OrderResult {
+	return w.Service.CreateOrdersCtx(ctx, orders)
+}
+func (w *won) CancelOrdersCtx(ctx context.Context, ids []int64) []pkg.CancelResult {
+	return w.Service.CancelOrdersCtx(ctx, ids)
+}
+func (w *won) CancelAllCtx(ctx context.Context, market string, side pkg.Side) (*pkg.CancelReport, error) {
+	return w.Service.CancelAllCtx(ctx, market, side)
+}
+func (w *won) CancelWhereCtx(ctx context.Context, match func(*pkg.Order) bool) (*pkg.CancelReport, error) {
+	return w.Service.CancelWhereCtx(ctx, match)
+}
+func (w *won) AmendOrderCtx(ctx context.Context, aor pkg.AmendOrderRequest) (*pkg.AmendResult, error) {
+	return w.Service.AmendOrderCtx(ctx, aor)
+}
+
+func (w *won) SyncTime(ctx context.Context) error {
+	return w.Service.SyncTime(ctx)
+}
+func (w *won) ClockOffset() time.Duration {
+	return w.Service.ClockOffset()
+}
+func (w *won) Close() error {
+	return w.Service.Close()
+}
+
+func (w *won) RateLimitBudget(bucket string) (pkg.Budget, bool) {
+	return w.Service.RateLimitBudget(bucket)
+}
+
+func (w *won) ExchangeInfo() (*pkg.ExchangeInfo, error) {
+	return w.Service.ExchangeInfo()
+}
+func (w *won) ExchangeInfoCtx(ctx context.Context) (*pkg.ExchangeInfo, error) {
+	return w.Service.ExchangeInfoCtx(ctx)
+}
+func (w *won) MarketInfo(market string) (*pkg.MarketInfo, error) {
+	return w.Service.MarketInfo(market)
+}
+func (w *won) MarketInfoCtx(ctx context.Context, market string) (*pkg.MarketInfo, error) {
+	return w.Service.MarketInfoCtx(ctx, market)
+}